@@ -0,0 +1,153 @@
+// Package archive writes articles to mbox files, Maildir folders and
+// trees of .eml files, and reads them back to replay into a server.
+//
+//   w := archive.NewMboxWriter(f)
+//   for _, id := range ids {
+//       a, err := conn.Article(id)
+//       ...
+//       err = w.WriteArticle(a)
+//   }
+//
+// Headers are written in their original order and form, as kept in
+// nntp.Article.Fields.
+package archive
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zeddD1abl0/nntp"
+)
+
+// A Writer stores articles in an archive.
+type Writer interface {
+	WriteArticle(a *nntp.Article) error
+}
+
+// A Reader returns the articles in an archive one at a time. Next returns
+// io.EOF after the last one.
+type Reader interface {
+	Next() (*nntp.Article, error)
+}
+
+// lines returns the article as lines without line endings.
+func lines(a *nntp.Article) []string {
+	var buf bytes.Buffer
+	a.WriteTo(&buf)
+	ls := strings.Split(buf.String(), "\n")
+	if n := len(ls); n > 0 && ls[n-1] == "" {
+		ls = ls[:n-1]
+	}
+	for i, l := range ls {
+		ls[i] = strings.TrimSuffix(l, "\r")
+	}
+	return ls
+}
+
+// writeFile writes data to path through a temporary file in dir, so that
+// readers never see a partial article.
+func writeFile(dir, path string, data []byte) error {
+	tmp, err := ioutil.TempFile(dir, ".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// fileReader reads one article per file.
+type fileReader struct {
+	paths []string
+}
+
+func (r *fileReader) Next() (*nntp.Article, error) {
+	if len(r.paths) == 0 {
+		return nil, io.EOF
+	}
+	f, err := os.Open(r.paths[0])
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r.paths = r.paths[1:]
+	return nntp.ReadArticle(f)
+}
+
+// A Tree stores each article as an RFC 5322 .eml file with CRLF line
+// endings, in a directory named after its first newsgroup:
+//
+//   comp/lang/go/1234@example.com.eml
+type Tree struct {
+	dir string
+}
+
+// NewTree returns a Tree rooted at dir.
+func NewTree(dir string) *Tree {
+	return &Tree{dir: dir}
+}
+
+// fileName turns a message-id into a safe file name.
+func fileName(msgid string) string {
+	msgid = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(msgid), "<"), ">")
+	name := strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, msgid)
+	if name == "" || name[0] == '.' {
+		name = "_" + name
+	}
+	return name
+}
+
+// WriteArticle implements Writer. An article already in the tree is
+// overwritten.
+func (t *Tree) WriteArticle(a *nntp.Article) error {
+	group := strings.TrimSpace(strings.Split(a.DecodedHeader("Newsgroups"), ",")[0])
+	if group == "" {
+		group = "_"
+	}
+	parts := strings.Split(group, ".")
+	for i, p := range parts {
+		parts[i] = fileName(p)
+	}
+	dir := filepath.Join(append([]string{t.dir}, parts...)...)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	a.WriteTo(&buf)
+	name := fileName(a.DecodedHeader("Message-ID")) + ".eml"
+	return writeFile(dir, filepath.Join(dir, name), buf.Bytes())
+}
+
+// ReadTree returns a Reader for the .eml files under dir, in path order.
+func ReadTree(dir string) (Reader, error) {
+	var paths []string
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err == nil && !fi.IsDir() && strings.HasSuffix(path, ".eml") {
+			paths = append(paths, path)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return &fileReader{paths: paths}, nil
+}
@@ -0,0 +1,229 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zeddD1abl0/nntp"
+)
+
+func testArticles(t *testing.T) []*nntp.Article {
+	var as []*nntp.Article
+	for i, body := range []string{
+		"Hello\r\nFrom here on\r\n>From quoted\r\n\r\n",
+		"Just one line\r\n",
+		"From the start\r\n. a dot\r\n",
+	} {
+		raw := fmt.Sprintf("Path: a!b\r\nFrom: User %d <u%d@example.com>\r\n"+
+			"Newsgroups: alt.test.archive,alt.other\r\nSubject: article %d\r\n"+
+			"Message-ID: <%d@example.com>\r\nDate: Mon, 01 Mar 2021 10:00:0%d +0000\r\n"+
+			"NNTP-Posting-Host: 192.0.2.1\r\nInjection-Info: news.example.com\r\n"+
+			"X-Trace: news.example.com 1614592800\r\n"+
+			"Xref: news alt.test.archive:%d\r\n\r\n%s", i, i, i, i, i, i, body)
+		a, err := nntp.ReadArticle(strings.NewReader(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		as = append(as, a)
+	}
+	return as
+}
+
+// text is the article with normalized line endings, for comparison.
+func text(a *nntp.Article) string {
+	return strings.Join(lines(a), "\n")
+}
+
+func readAll(t *testing.T, r Reader) []*nntp.Article {
+	var as []*nntp.Article
+	for {
+		a, err := r.Next()
+		if err == io.EOF {
+			return as
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		as = append(as, a)
+	}
+}
+
+func compare(t *testing.T, kind string, got, want []*nntp.Article) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: read %d articles, want %d", kind, len(got), len(want))
+	}
+	for i := range want {
+		if text(got[i]) != text(want[i]) {
+			t.Errorf("%s: article %d:\n%q\nwant\n%q", kind, i, text(got[i]), text(want[i]))
+		}
+	}
+}
+
+func TestMbox(t *testing.T) {
+	as := testArticles(t)
+	var buf bytes.Buffer
+	w := NewMboxWriter(&buf)
+	for _, a := range as {
+		if err := w.WriteArticle(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "From u0@example.com Mon Mar  1 10:00:00 2021\n") {
+		t.Errorf("bad separator: %q", out[:50])
+	}
+	if !strings.Contains(out, "\n>From here on\n>>From quoted\n") {
+		t.Errorf("From lines not quoted:\n%s", out)
+	}
+	compare(t, "mbox", readAll(t, NewMboxReader(&buf)), as)
+}
+
+func TestMaildirAndTree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	as := testArticles(t)
+
+	md, err := NewMaildir(filepath.Join(dir, "maildir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := NewTree(filepath.Join(dir, "tree"))
+	for _, a := range as {
+		if err := md.WriteArticle(a); err != nil {
+			t.Fatal(err)
+		}
+		if err := tree.WriteArticle(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := ReadMaildir(filepath.Join(dir, "maildir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, "maildir", readAll(t, r), as)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "tree", "alt", "test", "archive", "1@example.com.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("Subject: article 1\r\n")) {
+		t.Errorf(".eml file lacks CRLF header: %q", data)
+	}
+	r, err = ReadTree(filepath.Join(dir, "tree"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, "tree", readAll(t, r), as)
+}
+
+// sliceReader returns the given articles.
+type sliceReader []*nntp.Article
+
+func (s *sliceReader) Next() (*nntp.Article, error) {
+	if len(*s) == 0 {
+		return nil, io.EOF
+	}
+	a := (*s)[0]
+	*s = (*s)[1:]
+	return a, nil
+}
+
+// fakeServer answers each command and each terminating "." with the
+// next response, and returns everything the client sent once it hangs up.
+func fakeServer(t *testing.T, responses ...string) (net.Conn, <-chan string) {
+	client, server := net.Pipe()
+	sent := make(chan string, 1)
+	go func() {
+		var buf bytes.Buffer
+		defer func() { sent <- buf.String() }()
+		io.WriteString(server, "200 hi\r\n")
+		br := bufio.NewReader(server)
+		inData := false
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+			buf.WriteString(line)
+			if inData && line != ".\r\n" {
+				continue
+			}
+			resp := responses[0]
+			responses = responses[1:]
+			inData = strings.HasPrefix(resp, "335") || strings.HasPrefix(resp, "340")
+			io.WriteString(server, resp+"\r\n")
+		}
+	}()
+	return client, sent
+}
+
+func TestImportIHave(t *testing.T) {
+	client, sent := fakeServer(t, "335 send it", "235 thanks", "435 already have it", "335 send it", "437 rejected")
+	conn, err := nntp.NewClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetLogger(nil)
+	r := sliceReader(testArticles(t))
+	st, err := Import(&r, conn, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st != (ImportStats{Sent: 1, Duplicates: 1, Rejected: 1}) {
+		t.Errorf("stats %+v", st)
+	}
+	client.Close()
+	out := <-sent
+	if !strings.HasPrefix(out, "IHAVE <0@example.com>\r\nPath: a!b\r\n") {
+		t.Errorf("unexpected start:\n%s", out)
+	}
+	if strings.Contains(out, "Xref") {
+		t.Error("Xref header was sent")
+	}
+	if !strings.Contains(out, "\r\n.. a dot\r\n.\r\n") {
+		t.Errorf("dot-stuffing missing:\n%s", out)
+	}
+}
+
+func TestImportPost(t *testing.T) {
+	client, sent := fakeServer(t, "340 send it", "240 thanks")
+	conn, err := nntp.NewClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetLogger(nil)
+	r := sliceReader(testArticles(t)[:1])
+	st, err := Import(&r, conn, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st != (ImportStats{Sent: 1}) {
+		t.Errorf("stats %+v", st)
+	}
+	client.Close()
+	out := <-sent
+	want := "POST\r\n" +
+		"From: User 0 <u0@example.com>\r\n" +
+		"Newsgroups: alt.test.archive,alt.other\r\n" +
+		"Subject: article 0\r\n" +
+		"Message-ID: <0@example.com>\r\n" +
+		"Date: Mon, 01 Mar 2021 10:00:00 +0000\r\n" +
+		"\r\n" +
+		"Hello\r\n"
+	if !strings.HasPrefix(out, want) {
+		t.Errorf("posted:\n%s\nwant start:\n%s", out, want)
+	}
+}
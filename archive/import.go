@@ -0,0 +1,85 @@
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/textproto"
+)
+
+// A Sender delivers articles to a server. *nntp.Conn and *nntp.Session
+// satisfy it.
+type Sender interface {
+	RawPost(r io.Reader) error
+	IHave(msgid string, r io.Reader) error
+}
+
+// ImportStats counts the outcome of an Import.
+type ImportStats struct {
+	// Sent articles were accepted by the server.
+	Sent int
+	// Duplicates were already on the server (IHAVE only).
+	Duplicates int
+	// Rejected articles were refused by the server.
+	Rejected int
+}
+
+// injectionHeaders are added by the server that injected or relayed an
+// article. RFC 5537 section 3.5 has posting agents leave them out, so
+// they are removed before POST.
+var injectionHeaders = []string{
+	"Path",
+	"NNTP-Posting-Host",
+	"NNTP-Posting-Date",
+	"Injection-Info",
+	"Injection-Date",
+	"X-Trace",
+	"X-Complaints-To",
+}
+
+// Import replays every article from r into the server. With ihave set the
+// articles are offered with IHAVE, as a peer would, keeping their
+// Message-ID and Path; otherwise they are posted with POST, which most
+// reader connections require, and the injection and transit headers such
+// as Path and Injection-Info are removed so the server adds its own. The
+// Xref header is removed either way, since it only makes sense on the
+// server that assigned it.
+//
+// Articles the server refuses are counted and skipped. Import stops at
+// the first other error, including a 436 asking to try again later.
+func Import(r Reader, dst Sender, ihave bool) (ImportStats, error) {
+	var st ImportStats
+	for {
+		a, err := r.Next()
+		if err == io.EOF {
+			return st, nil
+		}
+		if err != nil {
+			return st, err
+		}
+		a.DelHeader("Xref")
+		if !ihave {
+			for _, name := range injectionHeaders {
+				a.DelHeader(name)
+			}
+		}
+		var buf bytes.Buffer
+		a.WriteTo(&buf)
+		if ihave {
+			err = dst.IHave(a.HeaderValue("Message-ID"), &buf)
+		} else {
+			err = dst.RawPost(&buf)
+		}
+		var terr *textproto.Error
+		switch {
+		case err == nil:
+			st.Sent++
+		case errors.As(err, &terr) && terr.Code == 435:
+			st.Duplicates++
+		case errors.As(err, &terr) && (terr.Code == 437 || terr.Code == 441):
+			st.Rejected++
+		default:
+			return st, err
+		}
+	}
+}
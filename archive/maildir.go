@@ -0,0 +1,83 @@
+package archive
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zeddD1abl0/nntp"
+)
+
+// A Maildir delivers articles into a Maildir folder: each is written to
+// tmp and then moved to new under a unique name. Lines end in LF.
+type Maildir struct {
+	dir  string
+	host string
+	mu   sync.Mutex
+	seq  int
+}
+
+// NewMaildir returns a Maildir for dir, creating its tmp, new and cur
+// subdirectories if needed.
+func NewMaildir(dir string) (*Maildir, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, err
+		}
+	}
+	host, _ := os.Hostname()
+	host = strings.NewReplacer("/", `\057`, ":", `\072`).Replace(host)
+	return &Maildir{dir: dir, host: host}, nil
+}
+
+// uniqueName returns a file name following the Maildir conventions:
+// time, then microseconds, process id and a counter, then the host.
+func (m *Maildir) uniqueName() string {
+	m.mu.Lock()
+	m.seq++
+	seq := m.seq
+	m.mu.Unlock()
+	now := time.Now()
+	return fmt.Sprintf("%d.M%dP%dQ%d.%s", now.Unix(), now.Nanosecond()/1000, os.Getpid(), seq, m.host)
+}
+
+// WriteArticle implements Writer.
+func (m *Maildir) WriteArticle(a *nntp.Article) error {
+	name := m.uniqueName()
+	data := strings.Join(lines(a), "\n") + "\n"
+	tmp := filepath.Join(m.dir, "tmp", name)
+	if err := ioutil.WriteFile(tmp, []byte(data), 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(m.dir, "new", name)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// ReadMaildir returns a Reader for the messages in dir's new and cur
+// subdirectories, oldest first by name.
+func ReadMaildir(dir string) (Reader, error) {
+	var paths []string
+	for _, sub := range []string{"new", "cur"} {
+		infos, err := ioutil.ReadDir(filepath.Join(dir, sub))
+		if err != nil {
+			return nil, err
+		}
+		for _, fi := range infos {
+			if !fi.IsDir() && !strings.HasPrefix(fi.Name(), ".") {
+				paths = append(paths, filepath.Join(dir, sub, fi.Name()))
+			}
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return filepath.Base(paths[i]) < filepath.Base(paths[j])
+	})
+	return &fileReader{paths: paths}, nil
+}
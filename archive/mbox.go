@@ -0,0 +1,116 @@
+package archive
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/zeddD1abl0/nntp"
+)
+
+// An MboxWriter writes articles to an mbox file in the mboxrd variant:
+// body lines starting with "From ", after any number of ">", get one more
+// ">" so that the quoting can be undone exactly. Lines end in LF.
+type MboxWriter struct {
+	w *bufio.Writer
+}
+
+// NewMboxWriter returns an MboxWriter writing to w. It does not need to
+// be closed, but w is only complete after each WriteArticle returns.
+func NewMboxWriter(w io.Writer) *MboxWriter {
+	return &MboxWriter{w: bufio.NewWriter(w)}
+}
+
+// isFromLine reports whether line needs quoting in mboxrd.
+func isFromLine(line string) bool {
+	return strings.HasPrefix(strings.TrimLeft(line, ">"), "From ")
+}
+
+// WriteArticle implements Writer.
+func (m *MboxWriter) WriteArticle(a *nntp.Article) error {
+	sender := "MAILER-DAEMON"
+	if _, addr := nntp.ParseFrom(a.DecodedHeader("From")); addr != "" && !strings.ContainsAny(addr, " \t") {
+		sender = addr
+	}
+	date, err := nntp.ParseDate(a.DecodedHeader("Date"))
+	if err != nil {
+		date = time.Now()
+	}
+	m.w.WriteString("From " + sender + " " + date.UTC().Format(time.ANSIC) + "\n")
+	for _, line := range lines(a) {
+		if isFromLine(line) {
+			m.w.WriteByte('>')
+		}
+		m.w.WriteString(line)
+		m.w.WriteByte('\n')
+	}
+	m.w.WriteByte('\n')
+	return m.w.Flush()
+}
+
+// An MboxReader reads articles from an mboxrd file.
+type MboxReader struct {
+	r    *bufio.Reader
+	next string // the pending "From " line, if any
+	err  error
+}
+
+// NewMboxReader returns an MboxReader reading from r.
+func NewMboxReader(r io.Reader) *MboxReader {
+	return &MboxReader{r: bufio.NewReader(r)}
+}
+
+func (m *MboxReader) readLine() (string, error) {
+	line, err := m.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), err
+}
+
+// Next implements Reader.
+func (m *MboxReader) Next() (*nntp.Article, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	// Find the first separator.
+	for m.next == "" {
+		line, err := m.readLine()
+		if err != nil {
+			m.err = err
+			return nil, err
+		}
+		if strings.HasPrefix(line, "From ") {
+			m.next = line
+		}
+	}
+	m.next = ""
+	var msg []string
+	for {
+		line, err := m.readLine()
+		if err != nil {
+			m.err = err
+			break
+		}
+		// A separator is a "From " line after a blank line.
+		if strings.HasPrefix(line, "From ") && len(msg) > 0 && msg[len(msg)-1] == "" {
+			m.next = line
+			break
+		}
+		msg = append(msg, line)
+	}
+	if m.err != nil && m.err != io.EOF {
+		return nil, m.err
+	}
+	// Drop the blank line that separates messages.
+	if n := len(msg); n > 0 && msg[n-1] == "" {
+		msg = msg[:n-1]
+	}
+	for i, line := range msg {
+		if isFromLine(line) && line[0] == '>' {
+			msg[i] = line[1:]
+		}
+	}
+	return nntp.ReadArticle(strings.NewReader(strings.Join(msg, "\n") + "\n"))
+}
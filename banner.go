@@ -0,0 +1,99 @@
+package nntp
+
+import (
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+// An UnavailableError is returned when the server refuses service in its
+// greeting, with code 400 (temporarily unavailable) or 502 (permanently
+// unavailable, e.g. the client address is not allowed).
+type UnavailableError struct {
+	Code int
+	Msg  string
+}
+
+func (e *UnavailableError) Error() string {
+	if e.Code == 400 {
+		return "nntp: service temporarily unavailable: " + e.Msg
+	}
+	return "nntp: service unavailable: " + e.Msg
+}
+
+// Temporary reports whether connecting again later may succeed.
+func (e *UnavailableError) Temporary() bool {
+	return e.Code == 400
+}
+
+// Unwrap returns the server response as a *textproto.Error.
+func (e *UnavailableError) Unwrap() error {
+	return &textproto.Error{Code: e.Code, Msg: e.Msg}
+}
+
+// ServerInfo holds what could be learned about the server software from
+// its greeting. Fields are empty when unknown; the banner is free text, so
+// this is only a hint. The IMPLEMENTATION capability, if the server
+// offers it, is authoritative.
+type ServerInfo struct {
+	// Host is the host name the server announces, if any.
+	Host string
+	// Software is the name of a recognised server, such as "INN",
+	// "Diablo" or "Leafnode".
+	Software string
+	// Version is the software version, if given.
+	Version string
+}
+
+var serverPatterns = []struct {
+	name string
+	re   *regexp.Regexp
+}{
+	{"INN", regexp.MustCompile(`\bINN ([0-9][\w.]*)`)},
+	{"INN", regexp.MustCompile(`InterNetNews`)},
+	{"Diablo", regexp.MustCompile(`(?i)\bdiablo\b(?:[^0-9(]*\(?([0-9][\w.-]*))?`)},
+	{"Leafnode", regexp.MustCompile(`(?i)\bleafnode\b(?:.*?version ([0-9][\w.]*))?`)},
+	{"Typhoon", regexp.MustCompile(`(?i)\btyphoon\b(?: v?([0-9][\w.]*))?`)},
+	{"Cyclone", regexp.MustCompile(`(?i)\bcyclone\b(?: v?([0-9][\w.]*))?`)},
+	{"Highwinds", regexp.MustCompile(`(?i)\bhighwinds\b`)},
+	{"Microsoft NNTP Service", regexp.MustCompile(`NNTP Service ([0-9][\w.]*)`)},
+	{"INN", regexp.MustCompile(`\bNNRP\b`)},
+}
+
+// ParseBanner extracts server hints from a greeting such as
+// "news.example.com InterNetNews NNRP server INN 2.6.4 ready (posting ok)".
+func ParseBanner(banner string) ServerInfo {
+	var info ServerInfo
+	if f := strings.Fields(banner); len(f) > 0 {
+		host := strings.TrimRight(f[0], ".,:!")
+		if strings.Contains(host, ".") && !strings.ContainsAny(host, "()") {
+			info.Host = host
+		}
+	}
+	for _, p := range serverPatterns {
+		if m := p.re.FindStringSubmatch(banner); m != nil {
+			info.Software = p.name
+			if len(m) > 1 {
+				info.Version = strings.TrimRight(m[1], ".")
+			}
+			break
+		}
+	}
+	return info
+}
+
+// readGreeting reads the initial response and records the server's
+// posting permission and identity.
+func (c *Conn) readGreeting() error {
+	code, msg, err := c.conn.ReadCodeLine(20)
+	if err != nil {
+		if code == 400 || code == 502 {
+			return &UnavailableError{Code: code, Msg: msg}
+		}
+		return err
+	}
+	c.Banner = msg
+	c.PostingAllowed = code == 200
+	c.Server = ParseBanner(msg)
+	return nil
+}
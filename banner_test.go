@@ -0,0 +1,85 @@
+package nntp
+
+import (
+	"bytes"
+	"errors"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestParseBanner(t *testing.T) {
+	tests := []struct {
+		banner string
+		want   ServerInfo
+	}{
+		{"news.example.com InterNetNews NNRP server INN 2.6.4 ready (posting ok)",
+			ServerInfo{"news.example.com", "INN", "2.6.4"}},
+		{"news.example.net InterNetNews server INN 2.5.2 ready (transit mode)",
+			ServerInfo{"news.example.net", "INN", "2.5.2"}},
+		{"Leafnode NNTP Daemon, version 1.11.11 running at localhost",
+			ServerInfo{"", "Leafnode", "1.11.11"}},
+		{"news.example.org Diablo server (2.0.0) ready",
+			ServerInfo{"news.example.org", "Diablo", "2.0.0"}},
+		{"Welcome to Example Usenet (Typhoon v2.1.6)",
+			ServerInfo{"", "Typhoon", "2.1.6"}},
+		{"NNTP Service 6.0.3790.3959 Version: 6.0.3790.3959 Posting Allowed",
+			ServerInfo{"", "Microsoft NNTP Service", "6.0.3790.3959"}},
+		{"ready", ServerInfo{}},
+	}
+	for _, tt := range tests {
+		if got := ParseBanner(tt.banner); got != tt.want {
+			t.Errorf("ParseBanner(%q) = %+v, want %+v", tt.banner, got, tt.want)
+		}
+	}
+}
+
+func TestGreeting(t *testing.T) {
+	tests := []struct {
+		greeting string
+		posting  bool
+		code     int
+	}{
+		{"200 news.example.com INN 2.6.4 ready (posting ok)", true, 0},
+		{"201 news.example.com INN 2.6.4 ready (no posting)", false, 0},
+		{"400 too many connections", false, 400},
+		{"502 access denied", false, 502},
+	}
+	for _, tt := range tests {
+		c, err := newClient(faker{&bytes.Buffer{}, strings.NewReader(tt.greeting + "\r\n")})
+		if tt.code != 0 {
+			var uerr *UnavailableError
+			if !errors.As(err, &uerr) || uerr.Code != tt.code {
+				t.Errorf("%q: got error %v", tt.greeting, err)
+			}
+			if uerr != nil && uerr.Temporary() != (tt.code == 400) {
+				t.Errorf("%q: Temporary() = %v", tt.greeting, uerr.Temporary())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.greeting, err)
+			continue
+		}
+		if c.PostingAllowed != tt.posting {
+			t.Errorf("%q: PostingAllowed = %v", tt.greeting, c.PostingAllowed)
+		}
+		if c.Server.Software != "INN" {
+			t.Errorf("%q: Server = %+v", tt.greeting, c.Server)
+		}
+	}
+}
+
+func TestModeReaderPosting(t *testing.T) {
+	var out bytes.Buffer
+	c := &Conn{conn: textproto.NewConn(faker{&out, strings.NewReader("200 posting allowed\r\n")})}
+	if err := c.ModeReader(); err != nil {
+		t.Fatal(err)
+	}
+	if !c.PostingAllowed {
+		t.Error("PostingAllowed not set after 200 to MODE READER")
+	}
+	if !IsConnectionLost(&UnavailableError{Code: 400}) {
+		t.Error("400 greeting should count as a lost connection")
+	}
+}
@@ -0,0 +1,117 @@
+package binaries
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/zeddD1abl0/nntp"
+)
+
+func TestParseSubject(t *testing.T) {
+	tests := []struct {
+		subject string
+		want    Subject
+	}{
+		{`name.rar (03/57)`,
+			Subject{Release: "name", FileName: "name.rar", Part: 3, Parts: 57}},
+		{`[1/9] - "file.par2" yEnc (1/4)`,
+			Subject{Release: "file", FileName: "file.par2", FileIndex: 1, FileTotal: 9, Part: 1, Parts: 4, YEnc: true}},
+		{`My Release [03/15] - "my.release.part02.rar" yEnc (012/137) 19500000`,
+			Subject{Release: "My Release", FileName: "my.release.part02.rar", FileIndex: 3, FileTotal: 15, Part: 12, Parts: 137, Size: 19500000, YEnc: true}},
+		{`[PRiVATE]-[WtFnZb]-[5/9] - "show.s01e01.vol03+04.par2" yEnc (1/3)`,
+			Subject{Release: "PRiVATE - WtFnZb", FileName: "show.s01e01.vol03+04.par2", FileIndex: 5, FileTotal: 9, Part: 1, Parts: 3, YEnc: true}},
+		{`(2/9) "archive.7z.002" - 1.2 GB - yEnc (01/10)`,
+			Subject{Release: "archive", FileName: "archive.7z.002", FileIndex: 2, FileTotal: 9, Part: 1, Parts: 10, YEnc: true}},
+		{`Holiday pics - File 2 of 5 - beach.jpg (1 of 3)`,
+			Subject{Release: "Holiday pics", FileName: "beach.jpg", FileIndex: 2, FileTotal: 5, Part: 1, Parts: 3}},
+		{`[2/3] "readme.nfo"`,
+			Subject{Release: "readme", FileName: "readme.nfo", FileIndex: 2, FileTotal: 3, Part: 1, Parts: 1}},
+	}
+	for _, tt := range tests {
+		got, ok := ParseSubject(tt.subject)
+		if !ok {
+			t.Errorf("%q not recognized", tt.subject)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q:\n got %+v\nwant %+v", tt.subject, got, tt.want)
+		}
+	}
+
+	for _, s := range []string{"Re: question about go", "broken (5/3)", ""} {
+		if _, ok := ParseSubject(s); ok {
+			t.Errorf("%q should not be recognized", s)
+		}
+	}
+}
+
+func TestCollator(t *testing.T) {
+	c := NewCollator()
+	n := int64(0)
+	add := func(from, subject string, bytes int) bool {
+		n++
+		return c.Add(nntp.MessageOverview{
+			MessageNumber: n,
+			Subject:       subject,
+			From:          from,
+			MessageID:     fmt.Sprintf("<%d@test>", n),
+			Bytes:         bytes,
+		})
+	}
+
+	const poster = "poster <p@example.com>"
+	for file := 1; file <= 2; file++ {
+		for part := 1; part <= 3; part++ {
+			if file == 2 && part == 2 {
+				continue
+			}
+			s := fmt.Sprintf(`Big Set [%d/2] - "big.part%d.rar" yEnc (%d/3) 2000`, file, file, part)
+			if !add(poster, s, 1000) {
+				t.Fatalf("%q rejected", s)
+			}
+		}
+	}
+	if add(poster, `Big Set [1/2] - "big.part1.rar" yEnc (2/3) 2000`, 1000) {
+		t.Error("duplicate part should be rejected")
+	}
+	if add(poster, "not a binary", 10) {
+		t.Error("plain subject should be rejected")
+	}
+	// Same name from someone else is a separate release.
+	add("other <o@example.com>", `Big Set [1/1] - "big.rar" yEnc (1/1)`, 10)
+
+	rs := c.Releases()
+	if len(rs) != 2 {
+		t.Fatalf("expected 2 releases, got %d", len(rs))
+	}
+	var r *Release
+	for _, x := range rs {
+		if x.Poster == poster {
+			r = x
+		}
+	}
+	if r == nil || r.Name != "Big Set" || r.FileTotal != 2 || len(r.Files) != 2 {
+		t.Fatalf("unexpected release %+v", r)
+	}
+	if !r.Files[0].Complete() || r.Files[1].Complete() {
+		t.Error("first file should be complete, second not")
+	}
+	if m := r.Files[1].Missing(); len(m) != 1 || m[0] != 2 {
+		t.Errorf("expected part 2 missing, got %v", m)
+	}
+	if r.Complete() {
+		t.Error("release with missing part reported complete")
+	}
+
+	add(poster, `Big Set [2/2] - "big.part2.rar" yEnc (2/3) 2000`, 1000)
+	if !r.Complete() || r.Bytes() != 6000 {
+		t.Errorf("release should be complete with 6000 bytes, got %d", r.Bytes())
+	}
+
+	// Articles smaller than the announced size indicate truncation.
+	small := NewCollator()
+	small.Add(nntp.MessageOverview{Subject: `"x.bin" yEnc (1/1) 5000`, Bytes: 100})
+	if small.Releases()[0].Complete() {
+		t.Error("undersized file reported complete")
+	}
+}
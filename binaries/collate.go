@@ -0,0 +1,196 @@
+package binaries
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zeddD1abl0/nntp"
+)
+
+// A Segment is one article carrying part of a file.
+type Segment struct {
+	Part          int
+	MessageNumber int64
+	MessageID     string
+	Bytes         int
+}
+
+// A File is a posted file assembled from its segments.
+type File struct {
+	Name      string
+	Subject   string // subject of the first segment seen
+	Poster    string
+	Date      time.Time // date of the earliest segment
+	FileIndex int
+	Parts     int
+	// Size is the decoded size announced in the subject, or zero.
+	Size int64
+	// Segments sorted by part number. Duplicates keep the first article.
+	Segments []*Segment
+}
+
+// Bytes returns the total article size of the segments received so far.
+func (f *File) Bytes() int64 {
+	var n int64
+	for _, s := range f.Segments {
+		n += int64(s.Bytes)
+	}
+	return n
+}
+
+// Missing returns the part numbers that have not been seen.
+func (f *File) Missing() []int {
+	var res []int
+	i := 0
+	for p := 1; p <= f.Parts; p++ {
+		for i < len(f.Segments) && f.Segments[i].Part < p {
+			i++
+		}
+		if i == len(f.Segments) || f.Segments[i].Part != p {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// Complete reports whether every part of the file has been seen and,
+// when the subject announced a size, the articles are at least that large.
+// Encoded articles are always larger than the data they carry, so a
+// shortfall means a segment is truncated.
+func (f *File) Complete() bool {
+	if len(f.Missing()) > 0 {
+		return false
+	}
+	return f.Size == 0 || f.Bytes() >= f.Size
+}
+
+func (f *File) add(seg *Segment) bool {
+	i := sort.Search(len(f.Segments), func(i int) bool { return f.Segments[i].Part >= seg.Part })
+	if i < len(f.Segments) && f.Segments[i].Part == seg.Part {
+		return false
+	}
+	f.Segments = append(f.Segments, nil)
+	copy(f.Segments[i+1:], f.Segments[i:])
+	f.Segments[i] = seg
+	return true
+}
+
+// A Release is a set of files posted together.
+type Release struct {
+	Name   string
+	Poster string
+	// FileTotal is the number of files announced by the subjects, or zero
+	// if they carry no file counter.
+	FileTotal int
+	// Files sorted by file index, then name.
+	Files []*File
+}
+
+// Complete reports whether all announced files are present and complete.
+func (r *Release) Complete() bool {
+	if r.FileTotal > 0 && len(r.Files) < r.FileTotal {
+		return false
+	}
+	for _, f := range r.Files {
+		if !f.Complete() {
+			return false
+		}
+	}
+	return len(r.Files) > 0
+}
+
+// Bytes returns the total article size of the release.
+func (r *Release) Bytes() int64 {
+	var n int64
+	for _, f := range r.Files {
+		n += f.Bytes()
+	}
+	return n
+}
+
+// A Collator groups overviews of binary posts into files and releases.
+// Posts are keyed by poster so that identically named uploads from
+// different people stay apart. The zero value is not usable; call
+// NewCollator.
+type Collator struct {
+	releases map[string]*Release
+	files    map[string]*File
+}
+
+// NewCollator returns an empty Collator.
+func NewCollator() *Collator {
+	return &Collator{
+		releases: map[string]*Release{},
+		files:    map[string]*File{},
+	}
+}
+
+// Add files an overview under its release. It reports false if the
+// subject is not recognized as part of a binary post or the part was
+// already seen.
+func (c *Collator) Add(ov nntp.MessageOverview) bool {
+	s, ok := ParseSubject(ov.Subject)
+	if !ok || s.FileName == "" {
+		return false
+	}
+	poster := strings.TrimSpace(ov.From)
+	rkey := strings.Join([]string{poster, strings.ToLower(s.Release)}, "\x00")
+	r, ok := c.releases[rkey]
+	if !ok {
+		r = &Release{Name: s.Release, Poster: poster}
+		c.releases[rkey] = r
+	}
+	if s.FileTotal > r.FileTotal {
+		r.FileTotal = s.FileTotal
+	}
+
+	fkey := rkey + "\x00" + s.FileName
+	f, ok := c.files[fkey]
+	if !ok {
+		f = &File{
+			Name:      s.FileName,
+			Subject:   ov.Subject,
+			Poster:    poster,
+			Date:      ov.Date,
+			FileIndex: s.FileIndex,
+			Parts:     s.Parts,
+			Size:      s.Size,
+		}
+		c.files[fkey] = f
+		r.Files = append(r.Files, f)
+		sort.SliceStable(r.Files, func(i, j int) bool {
+			if r.Files[i].FileIndex != r.Files[j].FileIndex {
+				return r.Files[i].FileIndex < r.Files[j].FileIndex
+			}
+			return r.Files[i].Name < r.Files[j].Name
+		})
+	}
+	if s.Parts > f.Parts {
+		f.Parts = s.Parts
+	}
+	if !ov.Date.IsZero() && (f.Date.IsZero() || ov.Date.Before(f.Date)) {
+		f.Date = ov.Date
+	}
+	return f.add(&Segment{
+		Part:          s.Part,
+		MessageNumber: ov.MessageNumber,
+		MessageID:     ov.MessageID,
+		Bytes:         ov.Bytes,
+	})
+}
+
+// Releases returns all releases seen so far, sorted by name.
+func (c *Collator) Releases() []*Release {
+	res := make([]*Release, 0, len(c.releases))
+	for _, r := range c.releases {
+		res = append(res, r)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Name != res[j].Name {
+			return res[i].Name < res[j].Name
+		}
+		return res[i].Poster < res[j].Poster
+	})
+	return res
+}
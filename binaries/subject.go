@@ -0,0 +1,147 @@
+// Package binaries recognizes multipart binary posts from their subjects
+// and collates overview data into files and releases.
+//
+// Binary posts split a file across many articles and number the pieces in
+// the subject, typically as either
+//
+//   name.rar (03/57)
+//   Release Name [1/9] - "file.par2" yEnc (1/4)
+//
+// ParseSubject extracts those counters and names; a Collator groups
+// nntp.MessageOverview values by them and reports which files and releases
+// have every part.
+package binaries
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A Subject is the information recognized in the subject of one part of
+// a binary post.
+type Subject struct {
+	// Release is the name of the post the file belongs to, either taken
+	// from the text preceding the file counter or derived from FileName.
+	Release string
+	// FileName of the posted file. Quoted names are preferred.
+	FileName string
+	// FileIndex and FileTotal number the file within the release. Both are
+	// zero if the subject has no file counter.
+	FileIndex int
+	FileTotal int
+	// Part and Parts number this article within the file.
+	Part  int
+	Parts int
+	// Size is the decoded file size announced in the subject, or zero.
+	Size int64
+	// YEnc is set if the subject announces yEnc encoding.
+	YEnc bool
+}
+
+var (
+	counterRe   = regexp.MustCompile(`[\(\[]\s*(\d+)\s*(?:/|of)\s*(\d+)\s*[\)\]]`)
+	fileOfRe    = regexp.MustCompile(`(?i)\bfile\s+(\d+)\s+of\s+(\d+)\b`)
+	quotedRe    = regexp.MustCompile(`"([^"]+)"`)
+	nameRe      = regexp.MustCompile(`[^\s"\(\)\[\]]+\.[A-Za-z0-9]{1,5}\b`)
+	yencRe      = regexp.MustCompile(`(?i)\byenc\b`)
+	sizeRe      = regexp.MustCompile(`[\)\]]\s*(\d+)\s*$`)
+	numericRe   = regexp.MustCompile(`^[\d.,]+$`)
+	separatorRe = regexp.MustCompile(`^[\s\-:|]+|[\s\-:|]+$`)
+)
+
+// Extensions removed, in order, to derive a release name from a file name.
+var releaseSuffixes = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\.vol\d+[+-]\d+\.par2$`),
+	regexp.MustCompile(`(?i)\.part\d+\.rar$`),
+	regexp.MustCompile(`(?i)\.(r\d{2,3}|s\d{2}|\d{3})$`),
+	regexp.MustCompile(`(?i)\.(rar|par2|par|nfo|sfv|nzb|zip|7z|md5|txt)$`),
+}
+
+// ParseSubject recognizes the subject of a multipart binary post. It
+// reports false if no part counter could be found.
+func ParseSubject(subject string) (Subject, bool) {
+	var s Subject
+	counters := counterRe.FindAllStringSubmatchIndex(subject, -1)
+	if len(counters) == 0 {
+		return s, false
+	}
+
+	quoted := quotedRe.FindStringSubmatchIndex(subject)
+	last := counters[len(counters)-1]
+	fileCounter := -1
+	switch {
+	case len(counters) > 1:
+		fileCounter = len(counters) - 2
+		s.Part, s.Parts = atoi(subject, last[2:4]), atoi(subject, last[4:6])
+	case quoted != nil && last[1] <= quoted[0]:
+		// A lone counter before the file name numbers files, not parts.
+		fileCounter = 0
+		s.Part, s.Parts = 1, 1
+	default:
+		s.Part, s.Parts = atoi(subject, last[2:4]), atoi(subject, last[4:6])
+	}
+	if s.Parts == 0 || s.Part > s.Parts {
+		return Subject{}, false
+	}
+
+	releaseEnd := -1
+	if fileCounter >= 0 {
+		c := counters[fileCounter]
+		s.FileIndex, s.FileTotal = atoi(subject, c[2:4]), atoi(subject, c[4:6])
+		releaseEnd = c[0]
+	} else if m := fileOfRe.FindStringSubmatchIndex(subject); m != nil {
+		s.FileIndex, s.FileTotal = atoi(subject, m[2:4]), atoi(subject, m[4:6])
+		releaseEnd = m[0]
+	}
+
+	if quoted != nil {
+		s.FileName = strings.TrimSpace(subject[quoted[2]:quoted[3]])
+	} else {
+		s.FileName = guessFileName(subject[:last[0]])
+	}
+
+	s.YEnc = yencRe.MatchString(subject)
+	if m := sizeRe.FindStringSubmatch(subject[last[0]:]); m != nil {
+		s.Size, _ = strconv.ParseInt(m[1], 10, 64)
+	}
+
+	if releaseEnd > 0 {
+		s.Release = cleanRelease(subject[:releaseEnd])
+	}
+	if s.Release == "" {
+		s.Release = releaseFromFile(s.FileName)
+	}
+	return s, true
+}
+
+func atoi(s string, idx []int) int {
+	n, _ := strconv.Atoi(s[idx[0]:idx[1]])
+	return n
+}
+
+// guessFileName returns the last word of s that looks like a file name.
+func guessFileName(s string) string {
+	var name string
+	for _, m := range nameRe.FindAllString(s, -1) {
+		if !numericRe.MatchString(m) {
+			name = m
+		}
+	}
+	return name
+}
+
+func cleanRelease(s string) string {
+	s = yencRe.ReplaceAllString(s, "")
+	s = quotedRe.ReplaceAllString(s, "")
+	s = strings.NewReplacer("[", " ", "]", " ").Replace(s)
+	s = strings.Join(strings.Fields(s), " ")
+	return separatorRe.ReplaceAllString(s, "")
+}
+
+func releaseFromFile(name string) string {
+	for _, re := range releaseSuffixes {
+		name = re.ReplaceAllString(name, "")
+	}
+	return name
+}
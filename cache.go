@@ -0,0 +1,285 @@
+package nntp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An ArticleFetcher retrieves articles from a server. *Conn and *Session
+// satisfy it.
+type ArticleFetcher interface {
+	Article(id string) (*Article, error)
+	Head(id string) (*Article, error)
+	Body(id string) ([]string, error)
+}
+
+// An ArticleCache keeps raw articles on disk, one file per message-id,
+// evicting the least recently used ones to stay under a size limit. It is
+// safe for concurrent use, so one cache can front a pool of connections:
+//
+//   a, err := cache.Article(conn, "<id@example.com>")
+//
+// Only message-ids are cached; article numbers are passed through, since
+// they depend on the selected group.
+type ArticleCache struct {
+	dir     string
+	maxSize int64
+	// Compress makes new entries gzip-compressed. Existing entries are
+	// read either way.
+	Compress bool
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List // of *cacheEntry, most recent first
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	name string // file name relative to dir
+	size int64
+}
+
+// OpenArticleCache opens the cache in dir, creating it if needed, and
+// indexes the entries already there. maxSize is in bytes of disk space;
+// with zero or less every entry is evicted as soon as it is added.
+func OpenArticleCache(dir string, maxSize int64) (*ArticleCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	c := &ArticleCache{
+		dir:     dir,
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: map[string]*list.Element{},
+	}
+	type found struct {
+		cacheEntry
+		mtime time.Time
+	}
+	var files []found
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() || strings.HasPrefix(fi.Name(), ".") {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files = append(files, found{cacheEntry{rel, fi.Size()}, fi.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Modification times are bumped on every hit, so they give the
+	// recency order across restarts.
+	sort.Slice(files, func(i, j int) bool { return files[i].mtime.After(files[j].mtime) })
+	for _, f := range files {
+		e := f.cacheEntry
+		c.entries[cacheKey(e.name)] = c.lru.PushBack(&e)
+		c.size += e.size
+	}
+	c.mu.Lock()
+	err = c.evict()
+	c.mu.Unlock()
+	return c, err
+}
+
+// cacheKey maps a file name to its index key, the hash of the message-id.
+func cacheKey(name string) string {
+	return strings.TrimSuffix(filepath.Base(name), ".gz")
+}
+
+func hashID(msgid string) string {
+	sum := sha1.Sum([]byte(msgid))
+	return hex.EncodeToString(sum[:])
+}
+
+func isMessageID(id string) bool {
+	return strings.HasPrefix(id, "<") && strings.HasSuffix(id, ">")
+}
+
+// Size returns the disk space used by cached articles.
+func (c *ArticleCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Len returns the number of cached articles.
+func (c *ArticleCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Get returns a cached article, or false if it is not cached.
+func (c *ArticleCache) Get(msgid string) (*Article, bool) {
+	key := hashID(msgid)
+	c.mu.Lock()
+	el, ok := c.entries[key]
+	if ok {
+		c.lru.MoveToFront(el)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	path := filepath.Join(c.dir, el.Value.(*cacheEntry).name)
+	f, err := os.Open(path)
+	if err != nil {
+		c.remove(key, el)
+		return nil, false
+	}
+	defer f.Close()
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			c.remove(key, el)
+			return nil, false
+		}
+		r = zr
+	}
+	a, err := ReadArticle(r)
+	if err != nil {
+		c.remove(key, el)
+		return nil, false
+	}
+	return a, true
+}
+
+// Put stores an article, replacing any cached copy.
+func (c *ArticleCache) Put(msgid string, a *Article) error {
+	var buf bytes.Buffer
+	if c.Compress {
+		zw := gzip.NewWriter(&buf)
+		a.WriteTo(zw)
+		zw.Close()
+	} else {
+		a.WriteTo(&buf)
+	}
+	key := hashID(msgid)
+	name := filepath.Join(key[:2], key)
+	if c.Compress {
+		name += ".gz"
+	}
+	sub := filepath.Join(c.dir, key[:2])
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		return err
+	}
+	size := int64(buf.Len())
+	tmp, err := ioutil.TempFile(sub, ".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = buf.WriteTo(tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Rename first, so that if it fails any old entry and its file are
+	// left as they were.
+	if err = os.Rename(tmp.Name(), filepath.Join(c.dir, name)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if el, ok := c.entries[key]; ok {
+		old := el.Value.(*cacheEntry)
+		if old.name != name {
+			os.Remove(filepath.Join(c.dir, old.name))
+		}
+		c.size -= old.size
+		c.lru.Remove(el)
+	}
+	e := &cacheEntry{name: name, size: size}
+	c.entries[key] = c.lru.PushFront(e)
+	c.size += e.size
+	return c.evict()
+}
+
+// remove drops the entry el after reading it failed. It does nothing if
+// a concurrent Put has replaced the entry since el was looked up, so the
+// newer file is kept.
+func (c *ArticleCache) remove(key string, el *list.Element) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries[key] == el {
+		e := el.Value.(*cacheEntry)
+		os.Remove(filepath.Join(c.dir, e.name))
+		c.size -= e.size
+		c.lru.Remove(el)
+		delete(c.entries, key)
+	}
+}
+
+// evict removes the least recently used entries until the cache fits.
+// c.mu must be held.
+func (c *ArticleCache) evict() error {
+	for c.size > c.maxSize && c.lru.Len() > 0 {
+		el := c.lru.Back()
+		e := el.Value.(*cacheEntry)
+		if err := os.Remove(filepath.Join(c.dir, e.name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		c.size -= e.size
+		c.lru.Remove(el)
+		delete(c.entries, cacheKey(e.name))
+	}
+	return nil
+}
+
+// Article returns the article from the cache, or fetches it with f and
+// caches it.
+func (c *ArticleCache) Article(f ArticleFetcher, id string) (*Article, error) {
+	if !isMessageID(id) {
+		return f.Article(id)
+	}
+	if a, ok := c.Get(id); ok {
+		return a, nil
+	}
+	a, err := f.Article(id)
+	if err != nil {
+		return nil, err
+	}
+	return a, c.Put(id, a)
+}
+
+// Head returns the header of a cached article, or fetches just the
+// header with f. Headers alone are not cached.
+func (c *ArticleCache) Head(f ArticleFetcher, id string) (*Article, error) {
+	if isMessageID(id) {
+		if a, ok := c.Get(id); ok {
+			a.Body = nil
+			return a, nil
+		}
+	}
+	return f.Head(id)
+}
+
+// Body returns the body of a cached article, or fetches just the body
+// with f. Bodies alone are not cached.
+func (c *ArticleCache) Body(f ArticleFetcher, id string) ([]string, error) {
+	if isMessageID(id) {
+		if a, ok := c.Get(id); ok {
+			return a.Body, nil
+		}
+	}
+	return f.Body(id)
+}
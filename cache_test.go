@@ -0,0 +1,190 @@
+package nntp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// countingFetcher serves generated articles and counts fetches.
+type countingFetcher struct {
+	mu      sync.Mutex
+	fetches int
+}
+
+func (f *countingFetcher) Article(id string) (*Article, error) {
+	f.mu.Lock()
+	f.fetches++
+	f.mu.Unlock()
+	var h OrderedHeader
+	h.Add("Message-ID", id)
+	h.Add("Subject", "about "+id)
+	return &Article{Header: h.MIMEHeader(), Fields: h, Body: []string{"line one", "", "line three"}}, nil
+}
+
+func (f *countingFetcher) Head(id string) (*Article, error) {
+	a, err := f.Article(id)
+	a.Body = nil
+	return a, err
+}
+
+func (f *countingFetcher) Body(id string) ([]string, error) {
+	a, err := f.Article(id)
+	return a.Body, err
+}
+
+func TestArticleCache(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		dir, err := ioutil.TempDir("", "cache")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		c, err := OpenArticleCache(dir, 1<<20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Compress = compress
+		f := &countingFetcher{}
+
+		a, err := c.Article(f, "<1@x>")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := c.Article(f, "<1@x>")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.fetches != 1 {
+			t.Errorf("compress=%v: %d fetches, want 1", compress, f.fetches)
+		}
+		if b.Fields.Get("Subject") != "about <1@x>" || fmt.Sprint(b.Body) != fmt.Sprint(a.Body) {
+			t.Errorf("compress=%v: cached article differs: %+v", compress, b)
+		}
+		if body, _ := c.Body(f, "<1@x>"); len(body) != 3 || f.fetches != 1 {
+			t.Errorf("compress=%v: body %q after %d fetches", compress, body, f.fetches)
+		}
+		if h, _ := c.Head(f, "<1@x>"); h.Body != nil || f.fetches != 1 {
+			t.Errorf("compress=%v: head has body %q", compress, h.Body)
+		}
+		// Article numbers are never cached.
+		c.Article(f, "1")
+		c.Article(f, "1")
+		if f.fetches != 3 {
+			t.Errorf("compress=%v: %d fetches, want 3", compress, f.fetches)
+		}
+
+		// Reopening finds the entry again.
+		c2, err := OpenArticleCache(dir, 1<<20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := c2.Get("<1@x>"); !ok || c2.Len() != 1 || c2.Size() != c.Size() {
+			t.Errorf("compress=%v: reopened cache has %d entries, %d bytes", compress, c2.Len(), c2.Size())
+		}
+	}
+}
+
+func TestArticleCacheEviction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := &countingFetcher{}
+	a, _ := f.Article("<0@x>")
+	c, err := OpenArticleCache(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Put("<0@x>", a)
+	if c.Size() != 0 || c.Len() != 0 {
+		t.Fatalf("cache with no room kept %d entries", c.Len())
+	}
+
+	c.maxSize = 3 * 80 // room for three 65-byte articles
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c.Article(f, fmt.Sprintf("<%d@x>", i))
+		}(i)
+	}
+	wg.Wait()
+	c.Get("<0@x>") // make <0@x> recently used
+	c.Article(f, "<3@x>")
+	if _, ok := c.Get("<0@x>"); !ok {
+		t.Error("recently used entry was evicted")
+	}
+	if c.Size() > c.maxSize {
+		t.Errorf("size %d over limit %d", c.Size(), c.maxSize)
+	}
+	if c.Len() != 3 {
+		t.Errorf("%d entries, want 3", c.Len())
+	}
+}
+
+func TestArticleCacheStaleRemove(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := &countingFetcher{}
+	a, _ := f.Article("<0@x>")
+	c, err := OpenArticleCache(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Put("<0@x>", a)
+	key := hashID("<0@x>")
+	c.mu.Lock()
+	stale := c.entries[key]
+	c.mu.Unlock()
+
+	// A Put replaces the entry while a Get of the old one is failing.
+	c.Compress = true
+	c.Put("<0@x>", a)
+	c.remove(key, stale)
+	if _, ok := c.Get("<0@x>"); !ok {
+		t.Error("failed read of a replaced entry removed the new one")
+	}
+}
+
+func TestArticleCachePutRenameFails(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := &countingFetcher{}
+	a, _ := f.Article("<0@x>")
+	c, err := OpenArticleCache(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Put("<0@x>", a)
+	size := c.Size()
+
+	// A non-empty directory where the compressed entry would go makes
+	// the rename fail.
+	key := hashID("<0@x>")
+	block := filepath.Join(dir, key[:2], key+".gz")
+	if err := os.MkdirAll(filepath.Join(block, "x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	c.Compress = true
+	if err := c.Put("<0@x>", a); err == nil {
+		t.Fatal("Put over a directory succeeded")
+	}
+	if _, ok := c.Get("<0@x>"); !ok {
+		t.Error("failed Put dropped the old entry")
+	}
+	if c.Size() != size || c.Len() != 1 {
+		t.Errorf("size %d, %d entries after failed Put, want %d, 1", c.Size(), c.Len(), size)
+	}
+}
@@ -0,0 +1,187 @@
+package nntp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"io"
+	"strings"
+)
+
+// Hash schemes for Cancel-Lock and Cancel-Key, as registered by RFC 8315.
+// SHA-1 is only for servers that know nothing newer.
+const (
+	CancelSHA256 = "sha256"
+	CancelSHA1   = "sha1"
+)
+
+var cancelHashes = map[string]func() hash.Hash{
+	CancelSHA256: sha256.New,
+	CancelSHA1:   sha1.New,
+}
+
+// A CancelSecret protects articles from forged cancels and supersedes
+// with the Cancel-Lock and Cancel-Key headers of RFC 8315. Keys are
+// derived from the secret and each article's Message-ID with the HMAC
+// algorithm recommended in section 4, so nothing needs to be stored per
+// article:
+//
+//   s := &nntp.CancelSecret{Secret: secret}
+//   r, err := s.LockReader(article)
+//   ...
+//   err = conn.RawPost(r)
+//
+// To cancel or supersede the article later, call s.Unlock on the new
+// article with the old Message-ID.
+type CancelSecret struct {
+	// Secret is the key to the HMAC. It must be kept private and stay the
+	// same for as long as articles may need cancelling.
+	Secret []byte
+	// UID optionally identifies the poster, such as a login name, so that
+	// one secret can serve several users.
+	UID string
+	// Schemes are the hashes used, CancelSHA256 if empty.
+	Schemes []string
+}
+
+func (s *CancelSecret) schemes() []string {
+	if len(s.Schemes) == 0 {
+		return []string{CancelSHA256}
+	}
+	return s.Schemes
+}
+
+// key returns the c-key element for msgid: the Base64 of
+// HMAC(uid+mid, sec).
+func (s *CancelSecret) key(scheme, msgid string) (string, error) {
+	h, ok := cancelHashes[scheme]
+	if !ok {
+		return "", errors.New("nntp: unknown cancel scheme " + scheme)
+	}
+	if len(s.Secret) == 0 {
+		return "", errors.New("nntp: empty cancel secret")
+	}
+	mac := hmac.New(h, s.Secret)
+	io.WriteString(mac, s.UID+msgid)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// cancelLockFor returns the c-lock element matching a c-key element.
+func cancelLockFor(scheme, key string) string {
+	h := cancelHashes[scheme]()
+	io.WriteString(h, key)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// CancelLock returns the Cancel-Lock header value for the article msgid.
+func (s *CancelSecret) CancelLock(msgid string) (string, error) {
+	var elems []string
+	for _, scheme := range s.schemes() {
+		key, err := s.key(scheme, msgid)
+		if err != nil {
+			return "", err
+		}
+		elems = append(elems, scheme+":"+cancelLockFor(scheme, key))
+	}
+	return strings.Join(elems, " "), nil
+}
+
+// CancelKey returns the Cancel-Key header value that opens the lock of
+// the article msgid, for a cancel or superseding article.
+func (s *CancelSecret) CancelKey(msgid string) (string, error) {
+	var elems []string
+	for _, scheme := range s.schemes() {
+		key, err := s.key(scheme, msgid)
+		if err != nil {
+			return "", err
+		}
+		elems = append(elems, scheme+":"+key)
+	}
+	return strings.Join(elems, " "), nil
+}
+
+// addHeaderElems appends elements to a header of a, creating the header
+// if needed.
+func addHeaderElems(a *Article, name, elems string) {
+	if old := a.HeaderValue(name); old != "" {
+		elems = old + " " + elems
+	}
+	a.SetHeader(name, elems)
+}
+
+// Lock adds a Cancel-Lock header to the article, or adds to the one it
+// has. The article must have its Message-ID already.
+func (s *CancelSecret) Lock(a *Article) error {
+	msgid := a.HeaderValue("Message-Id")
+	if msgid == "" {
+		return errors.New("nntp: Cancel-Lock needs a Message-ID")
+	}
+	lock, err := s.CancelLock(msgid)
+	if err != nil {
+		return err
+	}
+	addHeaderElems(a, "Cancel-Lock", lock)
+	return nil
+}
+
+// Unlock adds a Cancel-Key header for the article target to a, which
+// should be a cancel or supersede of it.
+func (s *CancelSecret) Unlock(a *Article, target string) error {
+	key, err := s.CancelKey(target)
+	if err != nil {
+		return err
+	}
+	addHeaderElems(a, "Cancel-Key", key)
+	return nil
+}
+
+// LockReader reads an article as RawPost would take it and returns it
+// with a Cancel-Lock header added, ready for RawPost.
+func (s *CancelSecret) LockReader(r io.Reader) (io.Reader, error) {
+	a, err := ReadArticle(r)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.Lock(a); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	a.WriteTo(&buf)
+	return &buf, nil
+}
+
+// VerifyCancelKey reports whether a Cancel-Key header value opens a
+// Cancel-Lock header value: whether the hash of any key element matches
+// a lock element of the same scheme. Unknown schemes are ignored, so a
+// lock using only those cannot be opened.
+func VerifyCancelKey(lock, key string) bool {
+	locks := map[string][]string{}
+	for _, elem := range strings.Fields(lock) {
+		if i := strings.IndexByte(elem, ':'); i > 0 {
+			scheme := strings.ToLower(elem[:i])
+			locks[scheme] = append(locks[scheme], elem[i+1:])
+		}
+	}
+	for _, elem := range strings.Fields(key) {
+		i := strings.IndexByte(elem, ':')
+		if i <= 0 {
+			continue
+		}
+		scheme := strings.ToLower(elem[:i])
+		if _, ok := cancelHashes[scheme]; !ok {
+			continue
+		}
+		want := cancelLockFor(scheme, elem[i+1:])
+		for _, l := range locks[scheme] {
+			if subtle.ConstantTimeCompare([]byte(l), []byte(want)) == 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package nntp
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestVerifyCancelKey(t *testing.T) {
+	// The example from RFC 8315, section 2.
+	if !VerifyCancelKey("sha1:bNXHc6ohSmeHaRHHW56BIWZJt+4=", "sha1:aaaBBBcccDDDeeeFFF") {
+		t.Error("RFC 8315 example does not verify")
+	}
+	if VerifyCancelKey("sha1:bNXHc6ohSmeHaRHHW56BIWZJt+4=", "sha1:aaaBBBcccDDDeeeFFG") {
+		t.Error("wrong key verifies")
+	}
+	if VerifyCancelKey("sha1:bNXHc6ohSmeHaRHHW56BIWZJt+4=", "sha256:aaaBBBcccDDDeeeFFF") {
+		t.Error("key of another scheme verifies")
+	}
+}
+
+func TestCancelSecret(t *testing.T) {
+	s := &CancelSecret{Secret: []byte("secret"), UID: "alice", Schemes: []string{CancelSHA256, CancelSHA1}}
+	post := "From: alice@example.com\r\n" +
+		"Newsgroups: example.test\r\n" +
+		"Message-ID: <1@example.com>\r\n" +
+		"Cancel-Lock: sha1:bNXHc6ohSmeHaRHHW56BIWZJt+4=\r\n" +
+		"\r\n" +
+		"Hello.\r\n"
+	r, err := s.LockReader(strings.NewReader(post))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(r)
+	a, err := ReadArticle(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock := a.Fields.Get("Cancel-Lock")
+	if len(strings.Fields(lock)) != 3 || !strings.HasPrefix(lock, "sha1:bNXHc6ohSmeHaRHHW56BIWZJt+4= sha256:") {
+		t.Fatalf("Cancel-Lock: %q", lock)
+	}
+	if a.Body[0] != "Hello." {
+		t.Errorf("body changed: %q", a.Body)
+	}
+
+	cancel := &Article{Header: map[string][]string{"Control": {"cancel <1@example.com>"}}}
+	if err = s.Unlock(cancel, "<1@example.com>"); err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyCancelKey(lock, cancel.Header["Cancel-Key"][0]) {
+		t.Errorf("Cancel-Key %q does not open %q", cancel.Header["Cancel-Key"][0], lock)
+	}
+
+	// Another user or article gets a different key.
+	other := &CancelSecret{Secret: []byte("secret"), UID: "bob"}
+	if key, _ := other.CancelKey("<1@example.com>"); VerifyCancelKey(lock, key) {
+		t.Error("key for another UID verifies")
+	}
+	if key, _ := s.CancelKey("<2@example.com>"); VerifyCancelKey(lock, key) {
+		t.Error("key for another article verifies")
+	}
+
+	if _, err = s.LockReader(strings.NewReader("Subject: no id\r\n\r\nx\r\n")); err == nil {
+		t.Error("locking an article without Message-ID should fail")
+	}
+}
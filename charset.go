@@ -0,0 +1,181 @@
+package nntp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// FallbackCharset is assumed for header and body text that is not valid
+// UTF-8 and does not declare a charset. Windows-1252 is a superset of
+// ISO-8859-1 for printable characters and is what most such posts use.
+var FallbackCharset = "windows-1252"
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// lookupCharset finds the encoding for a MIME charset name. Names are
+// resolved with the WHATWG aliases, so "latin1", "cp1251", "koi8-r",
+// "sjis" and the like are all understood.
+func lookupCharset(name string) (encoding.Encoding, error) {
+	name = strings.Trim(strings.TrimSpace(name), `"`)
+	if strings.EqualFold(name, "utf-8") || strings.EqualFold(name, "us-ascii") || name == "" {
+		return unicode.UTF8, nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("nntp: unsupported charset %q", name)
+	}
+	return enc, nil
+}
+
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := lookupCharset(charset)
+	if err != nil {
+		return nil, err
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+// ToUTF8 converts b from the named charset to UTF-8.
+func ToUTF8(charset string, b []byte) (string, error) {
+	enc, err := lookupCharset(charset)
+	if err != nil {
+		return "", err
+	}
+	res, err := enc.NewDecoder().Bytes(b)
+	if err != nil {
+		return "", err
+	}
+	return string(res), nil
+}
+
+// toUTF8Fallback returns s unchanged if it is valid UTF-8 and otherwise
+// decodes it from FallbackCharset.
+func toUTF8Fallback(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	if res, err := ToUTF8(FallbackCharset, []byte(s)); err == nil {
+		return res
+	}
+	return strings.ToValidUTF8(s, "�")
+}
+
+// DecodeHeader returns the UTF-8 text of a header value, decoding RFC 2047
+// encoded-words such as "=?ISO-8859-1?Q?caf=E9?=". Raw 8-bit text is
+// assumed to be UTF-8, or FallbackCharset if it is not valid UTF-8.
+// Encoded-words that cannot be decoded are left as they are.
+func DecodeHeader(s string) string {
+	s = toUTF8Fallback(s)
+	if !strings.Contains(s, "=?") {
+		return s
+	}
+	res, err := wordDecoder.DecodeHeader(s)
+	if err != nil {
+		return s
+	}
+	return res
+}
+
+// ParseFrom splits a From header into the display name and the address,
+// with the name decoded to UTF-8. Besides RFC 5322 forms it accepts the
+// old "addr (Name)" style and unquoted names with special characters.
+func ParseFrom(from string) (name, address string) {
+	from = strings.TrimSpace(toUTF8Fallback(from))
+	p := mail.AddressParser{WordDecoder: wordDecoder}
+	if a, err := p.Parse(from); err == nil {
+		if a.Name == "" {
+			name = commentName(from)
+		} else {
+			name = a.Name
+		}
+		return name, a.Address
+	}
+
+	// Lenient fallback for headers net/mail rejects.
+	if i, j := strings.LastIndexByte(from, '<'), strings.LastIndexByte(from, '>'); i >= 0 && j > i {
+		name = strings.TrimSpace(from[:i])
+		address = strings.TrimSpace(from[i+1 : j])
+	} else if i := strings.IndexByte(from, '('); i >= 0 {
+		address = strings.TrimSpace(from[:i])
+		name = commentName(from)
+	} else {
+		address = from
+	}
+	name = strings.TrimSpace(strings.Trim(name, `"`))
+	return DecodeHeader(name), address
+}
+
+// commentName returns the text of a trailing "(comment)", used by old
+// software for the display name.
+func commentName(s string) string {
+	i, j := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+	if i < 0 || j < i {
+		return ""
+	}
+	return DecodeHeader(strings.TrimSpace(s[i+1 : j]))
+}
+
+// DecodedSubject returns the Subject with encoded-words decoded.
+func (m *MessageOverview) DecodedSubject() string {
+	return DecodeHeader(m.Subject)
+}
+
+// DecodedFrom returns the display name and address from the From field.
+func (m *MessageOverview) DecodedFrom() (name, address string) {
+	return ParseFrom(m.From)
+}
+
+// DecodedHeader returns the first value of the named header decoded to
+// UTF-8.
+func (a *Article) DecodedHeader(name string) string {
+	return DecodeHeader(a.HeaderValue(name))
+}
+
+// BodyText returns the body of a single-part article as UTF-8 text. The
+// Content-Transfer-Encoding (quoted-printable or base64) is undone and the
+// charset parameter of Content-Type honored; without one, the body is
+// treated like a raw header value.
+func (a *Article) BodyText() (string, error) {
+	raw := []byte(strings.Join(a.Body, "\r\n"))
+	if len(a.Body) > 0 {
+		raw = append(raw, "\r\n"...)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(a.HeaderValue("Content-Transfer-Encoding"))) {
+	case "quoted-printable":
+		b, err := ioutil.ReadAll(quotedprintable.NewReader(bytes.NewReader(raw)))
+		if err != nil {
+			return "", err
+		}
+		raw = b
+	case "base64":
+		b, err := ioutil.ReadAll(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(raw)))
+		if err != nil {
+			return "", err
+		}
+		raw = b
+	}
+
+	charset := ""
+	if ct := a.HeaderValue("Content-Type"); ct != "" {
+		if _, params, err := mime.ParseMediaType(ct); err == nil {
+			charset = params["charset"]
+		}
+	}
+	if charset == "" {
+		return toUTF8Fallback(string(raw)), nil
+	}
+	return ToUTF8(charset, raw)
+}
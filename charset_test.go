@@ -0,0 +1,65 @@
+package nntp
+
+import (
+	"testing"
+)
+
+func TestDecodeHeader(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain", "plain"},
+		{"=?UTF-8?B?w6l0w6k=?=", "été"},
+		{"=?ISO-8859-1?Q?caf=E9?= au lait", "café au lait"},
+		{"=?koi8-r?B?8NLJ18XU?=", "Привет"},
+		{"=?windows-1251?Q?=CF=F0=E8=E2=E5=F2?=", "Привет"},
+		{"=?Shift_JIS?B?k/qWe4zq?=", "日本語"},
+		{"=?bogus?Q?x?=", "=?bogus?Q?x?="},
+		{"na\xefve", "naïve"},
+	}
+	for _, tt := range tests {
+		if got := DecodeHeader(tt.in); got != tt.want {
+			t.Errorf("DecodeHeader(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseFrom(t *testing.T) {
+	tests := []struct{ in, name, addr string }{
+		{"Someone <s@example.com>", "Someone", "s@example.com"},
+		{`"Doe, John" <jd@example.com>`, "Doe, John", "jd@example.com"},
+		{"jd@example.com (John Doe)", "John Doe", "jd@example.com"},
+		{"=?UTF-8?Q?Ren=C3=A9?= <r@example.com>", "René", "r@example.com"},
+		{"Dr. Who <who@tardis>", "Dr. Who", "who@tardis"},
+		{"bare@example.com", "", "bare@example.com"},
+	}
+	for _, tt := range tests {
+		name, addr := ParseFrom(tt.in)
+		if name != tt.name || addr != tt.addr {
+			t.Errorf("ParseFrom(%q) = %q, %q; want %q, %q", tt.in, name, addr, tt.name, tt.addr)
+		}
+	}
+}
+
+func TestBodyText(t *testing.T) {
+	a := &Article{
+		Header: map[string][]string{
+			"Content-Type":              {"text/plain; charset=ISO-8859-15"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		},
+		Body: []string{"Prix: 5 =A4, soft=", "wrapped"},
+	}
+	got, err := a.BodyText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Prix: 5 €, softwrapped\r\n" {
+		t.Errorf("got %q", got)
+	}
+
+	a = &Article{
+		Header: map[string][]string{"Content-Transfer-Encoding": {"base64"}},
+		Body:   []string{"aGVsbG8g", "d29ybGQ="},
+	}
+	if got, err := a.BodyText(); err != nil || got != "hello world" {
+		t.Errorf("base64 body = %q, %v", got, err)
+	}
+}
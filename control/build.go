@@ -0,0 +1,231 @@
+// Package control builds and interprets Usenet control messages, as
+// described in RFC 5537: cancel, Supersedes, newgroup, rmgroup and
+// checkgroups.
+//
+// The builders return articles ready to post:
+//
+//   a, err := control.NewGroup(control.Message{
+//       From:     "Admin <admin@example.com>",
+//       Approved: "admin@example.com",
+//   }, control.GroupInfo{Name: "example.test", Description: "Testing."}, false)
+//   ...
+//   err = control.Post(conn, a)
+//
+// A Processor does the other half for a server or local spool: it
+// classifies incoming articles with Parse and applies the ones it is
+// allowed to.
+package control
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/zeddD1abl0/nntp"
+)
+
+// A Message holds the headers common to the control messages built by
+// this package.
+type Message struct {
+	// From is required.
+	From string
+	// Approved is required by most servers for group control messages.
+	Approved string
+	// MessageID is generated from the domain of From if empty.
+	MessageID string
+	// Date is the current time if zero.
+	Date time.Time
+	// Path defaults to "not-for-mail".
+	Path string
+}
+
+// GroupInfo describes a newsgroup, as in a newsgroups file.
+type GroupInfo struct {
+	Name        string
+	Description string
+}
+
+// moderatedSuffix marks the descriptions of moderated groups.
+const moderatedSuffix = " (Moderated)"
+
+// groupsFileMarker starts the description in newgroup bodies.
+const groupsFileMarker = "For your newsgroups file:"
+
+// ValidGroupName reports whether name can be used as a newsgroup name:
+// dot-separated components of letters, digits, "+", "-" and "_".
+func ValidGroupName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, part := range strings.Split(name, ".") {
+		if part == "" {
+			return false
+		}
+		for _, r := range part {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '+' || r == '-' || r == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// newMessageID returns a unique message-id in the domain of from.
+func newMessageID(from string) string {
+	domain := "localhost"
+	if _, addr := nntp.ParseFrom(from); strings.Contains(addr, "@") {
+		domain = addr[strings.LastIndex(addr, "@")+1:]
+	}
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "<" + time.Now().UTC().Format("20060102150405") + "." + hex.EncodeToString(b) + "@" + domain + ">"
+}
+
+// build returns a control article with the given Newsgroups, Control
+// value and body.
+func (m Message) build(newsgroups, control string, body []string) (*nntp.Article, error) {
+	if m.From == "" {
+		return nil, errors.New("control: message has no From")
+	}
+	if m.MessageID == "" {
+		m.MessageID = newMessageID(m.From)
+	}
+	if m.Date.IsZero() {
+		m.Date = time.Now()
+	}
+	if m.Path == "" {
+		m.Path = "not-for-mail"
+	}
+	var h nntp.OrderedHeader
+	h.Add("Path", m.Path)
+	h.Add("From", m.From)
+	h.Add("Newsgroups", newsgroups)
+	h.Add("Subject", "cmsg "+control)
+	h.Add("Message-ID", m.MessageID)
+	h.Add("Date", m.Date.Format(time.RFC1123Z))
+	if m.Approved != "" {
+		h.Add("Approved", m.Approved)
+	}
+	h.Add("Control", control)
+	return &nntp.Article{Header: h.MIMEHeader(), Fields: h, Body: body}, nil
+}
+
+// Cancel returns a message cancelling the article target, which was
+// posted to newsgroups. It is posted to the same groups, as RFC 5537
+// requires.
+func Cancel(m Message, target, newsgroups string) (*nntp.Article, error) {
+	if !validMessageID(target) {
+		return nil, errors.New("control: invalid message-id " + target)
+	}
+	return m.build(newsgroups, "cancel "+target, []string{"Cancel " + target})
+}
+
+// Supersede returns a copy of replacement that replaces the article
+// target. Missing From, Message-ID, Date and Path headers are filled in
+// from m.
+func Supersede(m Message, target string, replacement *nntp.Article) (*nntp.Article, error) {
+	if !validMessageID(target) {
+		return nil, errors.New("control: invalid message-id " + target)
+	}
+	h := append(nntp.OrderedHeader{}, replacement.Fields...)
+	if replacement.Fields == nil {
+		for name, vs := range replacement.Header {
+			for _, v := range vs {
+				h.Add(name, v)
+			}
+		}
+	}
+	if h.Get("From") == "" {
+		if m.From == "" {
+			return nil, errors.New("control: message has no From")
+		}
+		h.Set("From", m.From)
+	}
+	if h.Get("Message-ID") == "" {
+		id := m.MessageID
+		if id == "" {
+			id = newMessageID(h.Get("From"))
+		}
+		h.Set("Message-ID", id)
+	}
+	if h.Get("Date") == "" {
+		d := m.Date
+		if d.IsZero() {
+			d = time.Now()
+		}
+		h.Set("Date", d.Format(time.RFC1123Z))
+	}
+	if h.Get("Path") == "" {
+		p := m.Path
+		if p == "" {
+			p = "not-for-mail"
+		}
+		h.Set("Path", p)
+	}
+	h.Set("Supersedes", target)
+	body := append([]string{}, replacement.Body...)
+	return &nntp.Article{Header: h.MIMEHeader(), Fields: h, Body: body}, nil
+}
+
+// NewGroup returns a message creating or changing the group g.
+func NewGroup(m Message, g GroupInfo, moderated bool) (*nntp.Article, error) {
+	if !ValidGroupName(g.Name) {
+		return nil, errors.New("control: invalid group name " + g.Name)
+	}
+	control := "newgroup " + g.Name
+	desc := g.Description
+	kind := "unmoderated"
+	if moderated {
+		control += " moderated"
+		kind = "moderated"
+		if !strings.HasSuffix(desc, moderatedSuffix) {
+			desc += moderatedSuffix
+		}
+	}
+	body := []string{
+		g.Name + " is a " + kind + " newsgroup.",
+		"",
+		groupsFileMarker,
+		g.Name + "\t" + desc,
+	}
+	return m.build(g.Name, control, body)
+}
+
+// RmGroup returns a message removing the group name.
+func RmGroup(m Message, name string) (*nntp.Article, error) {
+	if !ValidGroupName(name) {
+		return nil, errors.New("control: invalid group name " + name)
+	}
+	return m.build(name, "rmgroup "+name, []string{name + " is removed."})
+}
+
+// CheckGroups returns a message listing every group within scope, a list
+// of hierarchies that may include negated ones such as "!example.local".
+// Serial, if set, lets receivers ignore older lists. It is posted to
+// newsgroups, usually the hierarchy's announcement group.
+func CheckGroups(m Message, newsgroups string, scope []string, serial string, groups []GroupInfo) (*nntp.Article, error) {
+	control := "checkgroups"
+	for _, s := range scope {
+		if !ValidGroupName(strings.TrimPrefix(s, "!")) {
+			return nil, errors.New("control: invalid scope " + s)
+		}
+		control += " " + s
+	}
+	if serial != "" {
+		control += " #" + serial
+	}
+	var body []string
+	for _, g := range groups {
+		if !ValidGroupName(g.Name) {
+			return nil, errors.New("control: invalid group name " + g.Name)
+		}
+		body = append(body, g.Name+"\t"+g.Description)
+	}
+	return m.build(newsgroups, control, body)
+}
+
+func validMessageID(id string) bool {
+	return len(id) > 2 && id[0] == '<' && id[len(id)-1] == '>' && strings.Contains(id, "@") && !strings.ContainsAny(id, " \t")
+}
@@ -0,0 +1,232 @@
+package control
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeddD1abl0/nntp"
+)
+
+var admin = Message{
+	From:     "Admin <admin@example.com>",
+	Approved: "admin@example.com",
+	Date:     time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+}
+
+// roundTrip writes the article and reads it back, as a server would
+// receive it.
+func roundTrip(t *testing.T, a *nntp.Article) *nntp.Article {
+	var buf bytes.Buffer
+	a.WriteTo(&buf)
+	b, err := nntp.ReadArticle(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBuildAndParse(t *testing.T) {
+	a, err := Cancel(admin, "<old@example.com>", "example.test,example.misc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a = roundTrip(t, a)
+	if a.Fields.Get("Newsgroups") != "example.test,example.misc" || !strings.HasSuffix(a.Fields.Get("Message-ID"), "@example.com>") {
+		t.Errorf("cancel header:\n%s", a)
+	}
+	cmd, err := Parse(a)
+	if err != nil || cmd.Kind != KindCancel || cmd.Target != "<old@example.com>" {
+		t.Errorf("Parse(cancel) = %+v, %v", cmd, err)
+	}
+
+	a, err = NewGroup(admin, GroupInfo{"example.test", "Testing things."}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd, err = Parse(roundTrip(t, a))
+	if err != nil || cmd.Kind != KindNewGroup || !cmd.Moderated ||
+		cmd.Group != (GroupInfo{"example.test", "Testing things. (Moderated)"}) {
+		t.Errorf("Parse(newgroup) = %+v, %v", cmd, err)
+	}
+
+	a, err = CheckGroups(admin, "example.admin", []string{"example", "!example.local"}, "42",
+		[]GroupInfo{{"example.test", "Testing."}, {"example.misc", "Other things."}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Fields.Get("Control"); got != "checkgroups example !example.local #42" {
+		t.Errorf("checkgroups Control = %q", got)
+	}
+	cmd, err = Parse(roundTrip(t, a))
+	if err != nil || cmd.Kind != KindCheckGroups || cmd.Serial != "42" || len(cmd.Groups) != 2 {
+		t.Fatalf("Parse(checkgroups) = %+v, %v", cmd, err)
+	}
+	for group, want := range map[string]bool{
+		"example.test":      true,
+		"example.local.foo": false,
+		"other.test":        false,
+		"examples":          false,
+	} {
+		if cmd.InScope(group) != want {
+			t.Errorf("InScope(%q) = %v", group, !want)
+		}
+	}
+
+	orig := &nntp.Article{Header: map[string][]string{"Subject": {"Fixed"}, "Newsgroups": {"example.test"}}, Body: []string{"new text"}}
+	a, err = Supersede(admin, "<old@example.com>", orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd, err = Parse(roundTrip(t, a))
+	if err != nil || cmd.Kind != KindSupersede || cmd.Target != "<old@example.com>" {
+		t.Errorf("Parse(supersede) = %+v, %v", cmd, err)
+	}
+	if orig.Header["Supersedes"] != nil {
+		t.Error("Supersede modified its argument")
+	}
+
+	for _, control := range []string{"cancel foo", "newgroup bad..name", "rmgroup", "newgroup a.b maybe", "  "} {
+		a := &nntp.Article{Header: map[string][]string{"Control": {control}}}
+		if _, err := Parse(a); err == nil {
+			t.Errorf("Parse(%q) should fail", control)
+		}
+	}
+	cmd, err = Parse(&nntp.Article{Header: map[string][]string{"Control": {"sendme foo"}}})
+	if err != nil || cmd.Kind != KindOther || cmd.Verb != "sendme" {
+		t.Errorf("Parse(sendme) = %+v, %v", cmd, err)
+	}
+}
+
+// memSpool records changes in memory.
+type memSpool struct {
+	groups    map[string]GroupInfo
+	cancelled []string
+}
+
+func (s *memSpool) Cancel(msgid string) error {
+	s.cancelled = append(s.cancelled, msgid)
+	return nil
+}
+
+func (s *memSpool) NewGroup(g GroupInfo, moderated bool) error {
+	s.groups[g.Name] = g
+	return nil
+}
+
+func (s *memSpool) RmGroup(name string) error {
+	delete(s.groups, name)
+	return nil
+}
+
+func (s *memSpool) Groups() ([]string, error) {
+	var names []string
+	for name := range s.groups {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (s *memSpool) names() []string {
+	names, _ := s.Groups()
+	sort.Strings(names)
+	return names
+}
+
+func TestProcessor(t *testing.T) {
+	spool := &memSpool{groups: map[string]GroupInfo{
+		"example.old":   {},
+		"example.local": {},
+		"other.group":   {},
+	}}
+	p := &Processor{Spool: spool, Groups: "example.*"}
+
+	process := func(a *nntp.Article, err error) error {
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = p.Process(roundTrip(t, a))
+		return err
+	}
+
+	if err := process(NewGroup(admin, GroupInfo{"example.new", "New."}, false)); err != nil {
+		t.Fatal(err)
+	}
+	if err := process(NewGroup(admin, GroupInfo{"other.new", "New."}, false)); err != ErrOutOfScope {
+		t.Errorf("newgroup outside Groups: %v", err)
+	}
+	unapproved := admin
+	unapproved.Approved = ""
+	if err := process(RmGroup(unapproved, "example.old")); err != ErrNotApproved {
+		t.Errorf("rmgroup without Approved: %v", err)
+	}
+	if err := process(Cancel(unapproved, "<a@b>", "other.group,example.new")); err != nil {
+		t.Fatal(err)
+	}
+	if err := process(Cancel(unapproved, "<c@d>", "other.group")); err != ErrOutOfScope {
+		t.Errorf("cancel outside Groups: %v", err)
+	}
+	if !reflect.DeepEqual(spool.cancelled, []string{"<a@b>"}) {
+		t.Errorf("cancelled %v", spool.cancelled)
+	}
+
+	denied := errors.New("denied")
+	p.Authorize = func(cmd *Command, a *nntp.Article) error {
+		if cmd.Kind == KindRmGroup {
+			return denied
+		}
+		return nil
+	}
+	if err := process(RmGroup(admin, "example.old")); err != denied {
+		t.Errorf("rmgroup refused by Authorize: %v", err)
+	}
+
+	err := process(CheckGroups(admin, "example.admin", []string{"example", "!example.local"}, "",
+		[]GroupInfo{{"example.new", "New."}, {"example.mod", "Moderated. (Moderated)"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"example.local", "example.mod", "example.new", "other.group"}
+	if got := spool.names(); !reflect.DeepEqual(got, want) {
+		t.Errorf("after checkgroups: %v, want %v", got, want)
+	}
+}
+
+func TestRequireCancelKey(t *testing.T) {
+	secret := &nntp.CancelSecret{Secret: []byte("secret")}
+	orig := &nntp.Article{Header: map[string][]string{"Message-Id": {"<1@example.com>"}}}
+	if err := secret.Lock(orig); err != nil {
+		t.Fatal(err)
+	}
+	spool := &memSpool{groups: map[string]GroupInfo{}}
+	p := &Processor{
+		Spool: spool,
+		Authorize: RequireCancelKey(func(msgid string) (*nntp.Article, error) {
+			if msgid == "<1@example.com>" {
+				return orig, nil
+			}
+			return nil, nil
+		}),
+	}
+
+	forged, _ := Cancel(admin, "<1@example.com>", "example.test")
+	if _, err := p.Process(roundTrip(t, forged)); err != ErrBadCancelKey {
+		t.Errorf("cancel without key: %v", err)
+	}
+	real, _ := Cancel(admin, "<1@example.com>", "example.test")
+	secret.Unlock(real, "<1@example.com>")
+	if _, err := p.Process(roundTrip(t, real)); err != nil {
+		t.Errorf("cancel with key: %v", err)
+	}
+	unknown, _ := Cancel(admin, "<2@example.com>", "example.test")
+	if _, err := p.Process(roundTrip(t, unknown)); err != nil {
+		t.Errorf("cancel of unknown article: %v", err)
+	}
+	if !reflect.DeepEqual(spool.cancelled, []string{"<1@example.com>", "<2@example.com>"}) {
+		t.Errorf("cancelled %v", spool.cancelled)
+	}
+}
@@ -0,0 +1,204 @@
+package control
+
+import (
+	"strings"
+
+	"github.com/zeddD1abl0/nntp"
+)
+
+// A Kind is the type of a control message.
+type Kind int
+
+// Kinds of control message. KindOther covers the obsolete and unknown
+// ones, such as ihave, sendme and version.
+const (
+	KindNone Kind = iota
+	KindCancel
+	KindSupersede
+	KindNewGroup
+	KindRmGroup
+	KindCheckGroups
+	KindOther
+)
+
+var kindNames = []string{"none", "cancel", "supersede", "newgroup", "rmgroup", "checkgroups", "other"}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return "unknown"
+	}
+	return kindNames[k]
+}
+
+// A Command is a control message classified by Parse.
+type Command struct {
+	Kind Kind
+	// Verb and Args are the words of the Control header, with the verb in
+	// lower case. They are empty for a Supersedes.
+	Verb string
+	Args []string
+	// Target is the message-id cancelled or superseded.
+	Target string
+	// Group is the group created or removed, with its description from
+	// the body of a newgroup if there is one.
+	Group     GroupInfo
+	Moderated bool
+	// Scope, Serial and Groups are set for checkgroups.
+	Scope  []string
+	Serial string
+	Groups []GroupInfo
+}
+
+// ParseError reports a malformed control message.
+type ParseError string
+
+func (e ParseError) Error() string {
+	return "control: " + string(e)
+}
+
+// Parse classifies the article. It returns a Command of KindNone for
+// ordinary articles, and a KindSupersede Command for those with a
+// Supersedes header. Also-Control, the obsolete header of RFC 1036, is
+// read if Control is absent.
+func Parse(a *nntp.Article) (*Command, error) {
+	value := a.HeaderValue("Control")
+	if value == "" {
+		value = a.HeaderValue("Also-Control")
+	}
+	if value == "" {
+		if target := strings.TrimSpace(a.HeaderValue("Supersedes")); target != "" {
+			// Only the first of several message-ids is acted on.
+			target = strings.Fields(target)[0]
+			if !validMessageID(target) {
+				return nil, ParseError("invalid Supersedes " + target)
+			}
+			return &Command{Kind: KindSupersede, Target: target}, nil
+		}
+		return &Command{Kind: KindNone}, nil
+	}
+	words := strings.Fields(value)
+	if len(words) == 0 {
+		return nil, ParseError("empty Control header")
+	}
+	cmd := &Command{Kind: KindOther, Verb: strings.ToLower(words[0]), Args: words[1:]}
+	switch cmd.Verb {
+	case "cancel":
+		if len(cmd.Args) != 1 || !validMessageID(cmd.Args[0]) {
+			return nil, ParseError("invalid cancel: " + value)
+		}
+		cmd.Kind, cmd.Target = KindCancel, cmd.Args[0]
+	case "newgroup":
+		if len(cmd.Args) < 1 || len(cmd.Args) > 2 || !ValidGroupName(cmd.Args[0]) {
+			return nil, ParseError("invalid newgroup: " + value)
+		}
+		cmd.Kind, cmd.Group.Name = KindNewGroup, cmd.Args[0]
+		if len(cmd.Args) == 2 {
+			switch strings.ToLower(cmd.Args[1]) {
+			case "moderated":
+				cmd.Moderated = true
+			case "y", "unmoderated":
+			default:
+				return nil, ParseError("invalid newgroup: " + value)
+			}
+		}
+		cmd.Group.Description = groupDescription(a.Body, cmd.Group.Name)
+	case "rmgroup":
+		if len(cmd.Args) != 1 || !ValidGroupName(cmd.Args[0]) {
+			return nil, ParseError("invalid rmgroup: " + value)
+		}
+		cmd.Kind, cmd.Group.Name = KindRmGroup, cmd.Args[0]
+	case "checkgroups":
+		cmd.Kind = KindCheckGroups
+		for _, arg := range cmd.Args {
+			switch {
+			case strings.HasPrefix(arg, "#"):
+				cmd.Serial = arg[1:]
+			case ValidGroupName(strings.TrimPrefix(arg, "!")):
+				cmd.Scope = append(cmd.Scope, arg)
+			default:
+				return nil, ParseError("invalid checkgroups: " + value)
+			}
+		}
+		groups, err := parseGroupList(a.Body)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Groups = groups
+	}
+	return cmd, nil
+}
+
+// groupDescription finds the line for name after the "For your
+// newsgroups file:" marker in a newgroup body.
+func groupDescription(body []string, name string) string {
+	found := false
+	for _, line := range body {
+		if strings.EqualFold(strings.TrimSpace(line), groupsFileMarker) {
+			found = true
+			continue
+		}
+		if !found {
+			continue
+		}
+		if g, ok := parseGroupLine(line); ok && g.Name == name {
+			return g.Description
+		}
+	}
+	return ""
+}
+
+// parseGroupLine splits a newsgroups file line into name and description.
+func parseGroupLine(line string) (GroupInfo, bool) {
+	line = strings.TrimSpace(line)
+	i := strings.IndexAny(line, " \t")
+	if i < 0 {
+		return GroupInfo{Name: line}, ValidGroupName(line)
+	}
+	name := line[:i]
+	return GroupInfo{Name: name, Description: strings.TrimSpace(line[i:])}, ValidGroupName(name)
+}
+
+// parseGroupList parses a checkgroups body, skipping blank lines.
+func parseGroupList(body []string) ([]GroupInfo, error) {
+	var groups []GroupInfo
+	for _, line := range body {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		g, ok := parseGroupLine(line)
+		if !ok {
+			return nil, ParseError("invalid checkgroups line: " + line)
+		}
+		groups = append(groups, g)
+	}
+	if len(groups) == 0 {
+		return nil, ParseError("checkgroups lists no groups")
+	}
+	return groups, nil
+}
+
+// InScope reports whether the group is within a checkgroups scope. An
+// empty scope covers the hierarchies of the listed groups.
+func (c *Command) InScope(group string) bool {
+	scope := c.Scope
+	if len(scope) == 0 {
+		seen := map[string]bool{}
+		for _, g := range c.Groups {
+			top := strings.SplitN(g.Name, ".", 2)[0]
+			if !seen[top] {
+				seen[top] = true
+				scope = append(scope, top)
+			}
+		}
+	}
+	in, best := false, -1
+	for _, s := range scope {
+		neg := strings.HasPrefix(s, "!")
+		s = strings.TrimPrefix(s, "!")
+		// The longest matching entry wins.
+		if (group == s || strings.HasPrefix(group, s+".")) && len(s) > best {
+			in, best = !neg, len(s)
+		}
+	}
+	return in
+}
@@ -0,0 +1,181 @@
+package control
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/zeddD1abl0/nntp"
+)
+
+// A Poster sends articles to a server. *nntp.Conn and *nntp.Session
+// satisfy it.
+type Poster interface {
+	RawPost(r io.Reader) error
+}
+
+// Post sends an article built by this package to the server.
+func Post(p Poster, a *nntp.Article) error {
+	var buf bytes.Buffer
+	if _, err := a.WriteTo(&buf); err != nil {
+		return err
+	}
+	return p.RawPost(&buf)
+}
+
+// A Spool is what a Processor changes: a server's article and group
+// database, or a local copy of one.
+type Spool interface {
+	// Cancel removes an article. An unknown message-id is not an error,
+	// since the cancel may arrive before the article.
+	Cancel(msgid string) error
+	// NewGroup creates a group or updates its description and status.
+	NewGroup(g GroupInfo, moderated bool) error
+	RmGroup(name string) error
+	// Groups returns the names of all groups, for checkgroups.
+	Groups() ([]string, error)
+}
+
+// Errors returned by Processor.Process for messages it will not act on.
+var (
+	ErrOutOfScope   = errors.New("control: groups not handled here")
+	ErrNotApproved  = errors.New("control: group control message without Approved header")
+	ErrBadCancelKey = errors.New("control: Cancel-Key does not match the article's Cancel-Lock")
+)
+
+// A Processor applies incoming control messages to a Spool.
+type Processor struct {
+	Spool Spool
+	// Groups is a wildmat of the groups whose control messages are acted
+	// on, such as the hierarchies run locally. Empty means all groups.
+	Groups string
+	// Authorize, if set, must return nil for a message to be acted on.
+	// Use it to check the Approved header against a list of hierarchy
+	// administrators, a PGP signature, or a Cancel-Key.
+	Authorize func(cmd *Command, a *nntp.Article) error
+}
+
+// Process classifies the article and applies it if it is a control
+// message or a Supersedes within scope. It returns the Command so the
+// caller can log or file it; ordinary articles and unknown control
+// messages are returned without any action taken.
+//
+// A cancel or supersede is in scope if any group in the article's
+// Newsgroups matches. Group control messages must name groups that match
+// and carry an Approved header. For checkgroups, groups within its scope
+// that are missing from the Spool are created and ones that are not
+// listed are removed.
+func (p *Processor) Process(a *nntp.Article) (*Command, error) {
+	cmd, err := Parse(a)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.Kind == KindNone || cmd.Kind == KindOther {
+		return cmd, nil
+	}
+	w, err := p.wildmat()
+	if err != nil {
+		return cmd, err
+	}
+	match := func(group string) bool { return w == nil || w.Match(group) }
+
+	switch cmd.Kind {
+	case KindCancel, KindSupersede:
+		in := false
+		for _, g := range strings.Split(a.HeaderValue("Newsgroups"), ",") {
+			in = in || match(strings.TrimSpace(g))
+		}
+		if !in {
+			return cmd, ErrOutOfScope
+		}
+	case KindNewGroup, KindRmGroup:
+		if !match(cmd.Group.Name) {
+			return cmd, ErrOutOfScope
+		}
+		fallthrough
+	default:
+		if a.HeaderValue("Approved") == "" {
+			return cmd, ErrNotApproved
+		}
+	}
+	if p.Authorize != nil {
+		if err := p.Authorize(cmd, a); err != nil {
+			return cmd, err
+		}
+	}
+
+	switch cmd.Kind {
+	case KindCancel, KindSupersede:
+		return cmd, p.Spool.Cancel(cmd.Target)
+	case KindNewGroup:
+		return cmd, p.Spool.NewGroup(cmd.Group, cmd.Moderated)
+	case KindRmGroup:
+		return cmd, p.Spool.RmGroup(cmd.Group.Name)
+	}
+	return cmd, p.checkGroups(cmd, match)
+}
+
+func (p *Processor) wildmat() (*nntp.Wildmat, error) {
+	if p.Groups == "" {
+		return nil, nil
+	}
+	return nntp.CompileWildmat(p.Groups)
+}
+
+// checkGroups brings the groups within cmd's scope in line with its list.
+func (p *Processor) checkGroups(cmd *Command, match func(string) bool) error {
+	have, err := p.Spool.Groups()
+	if err != nil {
+		return err
+	}
+	exists := map[string]bool{}
+	for _, name := range have {
+		exists[name] = true
+	}
+	listed := map[string]bool{}
+	for _, g := range cmd.Groups {
+		listed[g.Name] = true
+		if exists[g.Name] || !cmd.InScope(g.Name) || !match(g.Name) {
+			continue
+		}
+		moderated := strings.HasSuffix(g.Description, moderatedSuffix)
+		if err := p.Spool.NewGroup(g, moderated); err != nil {
+			return err
+		}
+	}
+	for _, name := range have {
+		if listed[name] || !cmd.InScope(name) || !match(name) {
+			continue
+		}
+		if err := p.Spool.RmGroup(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RequireCancelKey returns an Authorize function that enforces RFC 8315:
+// a cancel or supersede of an article with a Cancel-Lock header must
+// carry a Cancel-Key that opens it. Lookup returns the article being
+// cancelled, or nil if it is not known, in which case the cancel is
+// allowed since it may have arrived first. Other messages are allowed.
+func RequireCancelKey(lookup func(msgid string) (*nntp.Article, error)) func(*Command, *nntp.Article) error {
+	return func(cmd *Command, a *nntp.Article) error {
+		if cmd.Kind != KindCancel && cmd.Kind != KindSupersede {
+			return nil
+		}
+		target, err := lookup(cmd.Target)
+		if err != nil || target == nil {
+			return err
+		}
+		lock := target.HeaderValue("Cancel-Lock")
+		if lock == "" {
+			return nil
+		}
+		if !nntp.VerifyCancelKey(lock, a.HeaderValue("Cancel-Key")) {
+			return ErrBadCancelKey
+		}
+		return nil
+	}
+}
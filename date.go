@@ -3,11 +3,16 @@
 // license that can be found in the LICENSE file.
 
 // RFC5322 date parsing. Copied from net/mail Go standard
-// library package.
+// library package, with a fallback for the many other forms seen in
+// Usenet Date headers.
 package nntp
 
-import "errors"
-import "time"
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // Layouts suitable for passing to time.Parse.
 // These are tried in order.
@@ -16,12 +21,15 @@ var dateLayouts []string
 func init() {
 	// Generate layouts based on RFC 5322, section 3.3.
 
-	dows := [...]string{"", "Mon, "}   // day-of-week
-	days := [...]string{"2", "02"}     // day = 1*2DIGIT
-	years := [...]string{"2006", "06"} // year = 4*DIGIT / 2*DIGIT
-	seconds := [...]string{":05", ""}  // second
+	dows := [...]string{"", "Mon, "}  // day-of-week
+	days := [...]string{"2", "02"}    // day = 1*2DIGIT
+	years := [...]string{"2006"}      // year = 4*DIGIT
+	seconds := [...]string{":05", ""} // second
 	// "-0700 (MST)" is not in RFC 5322, but is common.
-	zones := [...]string{"-0700", "MST", "-0700 (MST)"} // zone = (("+" / "-") 4DIGIT) / "GMT" / ...
+	zones := [...]string{"-0700", "-0700 (MST)"} // zone = ("+" / "-") 4DIGIT
+	// Two-digit years and zone names are left to parseDateLoose, which
+	// windows the years and knows the offsets of the names; time.Parse
+	// takes any unknown name as UTC.
 
 	for _, dow := range dows {
 		for _, day := range days {
@@ -37,12 +45,346 @@ func init() {
 	}
 }
 
-func parseDate(date string) (time.Time, error) {
+var errBadDate = errors.New("date cannot be parsed")
+
+// ParseDate parses the value of a Date header, as found in articles and
+// overview data.
+//
+// Besides RFC 5322 dates it accepts the obsolete forms of RFC 822, RFC 850
+// and RFC 1036 ("Monday, 02-Jan-06 15:04:05 GMT"), asctime output, ISO
+// 8601, missing seconds or day of week, any letter case, comments,
+// numeric and named time zones, and two-digit years, which are taken as
+// 1950 to 2049. A date without a zone is taken as UTC.
+func ParseDate(date string) (time.Time, error) {
 	for _, layout := range dateLayouts {
 		t, err := time.Parse(layout, date)
 		if err == nil {
 			return t, nil
 		}
 	}
-	return time.Time{}, errors.New("date cannot be parsed")
+	return parseDateLoose(date)
+}
+
+// zoneOffsets are the offsets in seconds of zone names seen in Date
+// headers. Where a name is ambiguous the most common use on Usenet wins.
+var zoneOffsets = map[string]int{
+	"ut": 0, "utc": 0, "gmt": 0, "z": 0, "wet": 0, "gmt0": 0,
+	"edt": -4 * 3600, "est": -5 * 3600, "cdt": -5 * 3600, "cst": -6 * 3600,
+	"mdt": -6 * 3600, "mst": -7 * 3600, "pdt": -7 * 3600, "pst": -8 * 3600,
+	"akdt": -8 * 3600, "akst": -9 * 3600, "hst": -10 * 3600,
+	"adt": -3 * 3600, "ast": -4 * 3600, "ndt": -9000, "nst": -12600,
+	"bst": 3600, "ist": 19800, "west": 3600, "cet": 3600, "met": 3600,
+	"mez": 3600, "cest": 7200, "mest": 7200, "mesz": 7200, "eet": 7200,
+	"eest": 3 * 3600, "msk": 3 * 3600, "msd": 4 * 3600,
+	"sgt": 8 * 3600, "hkt": 8 * 3600, "awst": 8 * 3600, "jst": 9 * 3600,
+	"kst": 9 * 3600, "acst": 34200, "aest": 10 * 3600, "aedt": 11 * 3600,
+	"nzst": 12 * 3600, "nzdt": 13 * 3600,
+}
+
+var monthNames = map[string]time.Month{
+	"jan": time.January, "feb": time.February, "mar": time.March,
+	"apr": time.April, "may": time.May, "jun": time.June,
+	"jul": time.July, "aug": time.August, "sep": time.September,
+	"oct": time.October, "nov": time.November, "dec": time.December,
+}
+
+var dayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// stripComments removes parenthesized, possibly nested, comments and
+// returns the text of the last one, which often names the zone.
+func stripComments(s string) (string, string) {
+	var b, c strings.Builder
+	comment := ""
+	depth := 0
+	for _, r := range s {
+		switch {
+		case r == '(':
+			if depth == 0 {
+				c.Reset()
+			}
+			depth++
+			b.WriteByte(' ')
+		case r == ')' && depth > 0:
+			depth--
+			if depth == 0 {
+				comment = c.String()
+			}
+		case depth > 0:
+			c.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), strings.TrimSpace(comment)
+}
+
+// parseZone parses a numeric offset such as "+0100", "-05", "+05:30",
+// optionally after "GMT" or "UTC", or a zone name.
+func parseZone(s string) (int, bool) {
+	if off, ok := zoneOffsets[s]; ok {
+		return off, true
+	}
+	for _, p := range []string{"gmt", "utc", "ut"} {
+		if strings.HasPrefix(s, p) && len(s) > len(p) && (s[len(p)] == '+' || s[len(p)] == '-') {
+			s = s[len(p):]
+			break
+		}
+	}
+	if len(s) < 2 || s[0] != '+' && s[0] != '-' {
+		return 0, false
+	}
+	digits := strings.Replace(s[1:], ":", "", 1)
+	var h, m int
+	var err error
+	switch len(digits) {
+	case 1, 2:
+		h, err = strconv.Atoi(digits)
+	case 3, 4:
+		h, err = strconv.Atoi(digits[:len(digits)-2])
+		if err == nil {
+			m, err = strconv.Atoi(digits[len(digits)-2:])
+		}
+	default:
+		return 0, false
+	}
+	if err != nil || h > 14 || m > 59 {
+		return 0, false
+	}
+	off := h*3600 + m*60
+	if s[0] == '-' {
+		off = -off
+	}
+	return off, true
+}
+
+// parseClock parses "15:04", "15:04:05" or "15:04:05.999".
+func parseClock(s string) (h, m, sec, nsec int, ok bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return
+	}
+	var err error
+	if h, err = strconv.Atoi(parts[0]); err != nil || h > 24 {
+		return
+	}
+	if m, err = strconv.Atoi(parts[1]); err != nil || len(parts[1]) != 2 || m > 59 {
+		return
+	}
+	if len(parts) == 3 {
+		secs := parts[2]
+		if i := strings.IndexByte(secs, '.'); i >= 0 {
+			frac := secs[i+1:]
+			if frac == "" || len(frac) > 9 {
+				return
+			}
+			if nsec, err = strconv.Atoi(frac + strings.Repeat("0", 9-len(frac))); err != nil {
+				return
+			}
+			secs = secs[:i]
+		}
+		if sec, err = strconv.Atoi(secs); err != nil || len(secs) != 2 || sec > 60 {
+			return
+		}
+		if sec == 60 {
+			sec = 59 // leap second
+		}
+	}
+	if h == 24 && (m != 0 || sec != 0) {
+		return
+	}
+	return h, m, sec, nsec, true
+}
+
+// parseISODate parses ISO 8601 dates such as "2006-01-02T15:04:05Z" and
+// "2006-01-02 15:04:05+01:00".
+func parseISODate(s string) (time.Time, bool) {
+	if len(s) < 10 || s[4] != '-' || s[7] != '-' {
+		return time.Time{}, false
+	}
+	y, err1 := strconv.Atoi(s[:4])
+	mo, err2 := strconv.Atoi(s[5:7])
+	d, err3 := strconv.Atoi(s[8:10])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return time.Time{}, false
+	}
+	rest := strings.TrimSpace(s[10:])
+	var h, m, sec, nsec, off int
+	if rest != "" {
+		if rest[0] == 'T' || rest[0] == 't' {
+			rest = rest[1:]
+		}
+		end := strings.IndexAny(rest, "Zz+- ")
+		clock, zone := rest, ""
+		if end >= 0 {
+			clock, zone = rest[:end], strings.ToLower(strings.TrimSpace(rest[end:]))
+		}
+		var ok bool
+		if h, m, sec, nsec, ok = parseClock(clock); !ok {
+			return time.Time{}, false
+		}
+		if zone != "" {
+			if off, ok = parseZone(zone); !ok {
+				return time.Time{}, false
+			}
+		}
+	}
+	return makeDate(y, mo, d, h, m, sec, nsec, off)
+}
+
+// makeDate builds a time, rejecting out-of-range fields rather than
+// letting time.Date normalize them.
+func makeDate(y, mo, d, h, m, sec, nsec, off int) (time.Time, bool) {
+	if mo < 1 || mo > 12 || d < 1 || d > 31 {
+		return time.Time{}, false
+	}
+	t := time.Date(y, time.Month(mo), d, h, m, sec, nsec, time.FixedZone("", off))
+	if t.Day() != d && h != 24 {
+		return time.Time{}, false
+	}
+	if off == 0 {
+		t = t.UTC()
+	}
+	return t, true
+}
+
+// parseDateLoose parses a date by classifying its words rather than by
+// matching whole layouts.
+func parseDateLoose(date string) (time.Time, error) {
+	s, comment := stripComments(date)
+	s = strings.TrimSpace(s)
+	if t, ok := parseISODate(s); ok {
+		return t, nil
+	}
+	s = strings.ToLower(s)
+	// RFC 850 writes "02-Jan-06"; a dash followed by a month name or
+	// between day and year is a separator, not a zone sign.
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == '\t' || r == ',' })
+	var words []string
+	for _, f := range fields {
+		if p := strings.Split(f, "-"); len(p) == 3 && p[0] != "" && p[1] != "" && p[2] != "" && !strings.Contains(f, ":") {
+			words = append(words, p...)
+			continue
+		}
+		words = append(words, f)
+	}
+
+	day, year, month := -1, -1, time.Month(0)
+	h, m, sec, nsec := 0, 0, 0, 0
+	haveClock, haveZone, pm, am := false, false, false, false
+	off := 0
+	yearDigits := 0
+	for _, w := range words {
+		w = strings.TrimSuffix(w, ".")
+		if w == "" {
+			continue
+		}
+		if strings.Contains(w, ":") && !haveClock {
+			clock := w
+			if i := strings.IndexAny(w, "+-"); i > 0 {
+				clock = w[:i]
+				if o, ok := parseZone(w[i:]); ok && !haveZone {
+					off, haveZone = o, true
+				}
+			}
+			var ok bool
+			if h, m, sec, nsec, ok = parseClock(clock); !ok {
+				return time.Time{}, errBadDate
+			}
+			haveClock = true
+			continue
+		}
+		if w[0] >= '0' && w[0] <= '9' {
+			n, err := strconv.Atoi(w)
+			if err != nil {
+				return time.Time{}, errBadDate
+			}
+			switch {
+			case day < 0 && len(w) <= 2 && n >= 1 && n <= 31 && year < 0:
+				day = n
+			case year < 0:
+				year, yearDigits = n, len(w)
+			case day < 0 && len(w) <= 2 && n >= 1 && n <= 31:
+				day = n
+			default:
+				return time.Time{}, errBadDate
+			}
+			continue
+		}
+		if w == "am" || w == "pm" {
+			pm, am = w == "pm", w == "am"
+			continue
+		}
+		if len(w) >= 3 {
+			if mo, ok := monthNames[w[:3]]; ok && month == 0 && isMonthWord(w) {
+				month = mo
+				continue
+			}
+			if isDayWord(w) {
+				continue
+			}
+		}
+		if o, ok := parseZone(w); ok && !haveZone {
+			off, haveZone = o, true
+			continue
+		}
+		return time.Time{}, errBadDate
+	}
+	if day < 0 || year < 0 || month == 0 {
+		return time.Time{}, errBadDate
+	}
+	switch {
+	case yearDigits <= 2 && year < 50:
+		year += 2000
+	case yearDigits <= 2:
+		year += 1900
+	case yearDigits == 3:
+		year += 1900
+	}
+	if pm && h < 12 {
+		h += 12
+	} else if am && h == 12 {
+		h = 0
+	}
+	if !haveZone && comment != "" {
+		if o, ok := parseZone(strings.ToLower(comment)); ok {
+			off = o
+		}
+	}
+	t, ok := makeDate(year, int(month), day, h, m, sec, nsec, off)
+	if !ok {
+		return time.Time{}, errBadDate
+	}
+	return t, nil
+}
+
+// isMonthWord reports whether w is a month name or its abbreviation.
+func isMonthWord(w string) bool {
+	full := []string{"january", "february", "march", "april", "may", "june", "july",
+		"august", "september", "october", "november", "december", "sept"}
+	for _, f := range full {
+		if strings.HasPrefix(f, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDayWord reports whether w is a day name or its abbreviation.
+func isDayWord(w string) bool {
+	if len(w) < 3 {
+		return false
+	}
+	full := []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday",
+		"tues", "thur", "thurs"}
+	for _, f := range full {
+		if strings.HasPrefix(f, w) {
+			return true
+		}
+	}
+	for _, d := range dayNames {
+		if w == d {
+			return true
+		}
+	}
+	return false
 }
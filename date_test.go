@@ -0,0 +1,89 @@
+package nntp
+
+import (
+	"testing"
+	"time"
+)
+
+// dateCorpus holds Date headers in the forms seen on Usenet, with the
+// instant each should parse to in RFC 3339.
+var dateCorpus = []struct{ in, want string }{
+	{"Mon, 02 Jan 2006 15:04:05 -0700", "2006-01-02T15:04:05-07:00"},
+	{"2 Jan 2006 15:04 +0000", "2006-01-02T15:04:00Z"},
+	{"Mon, 2 Jan 06 15:04:05 GMT", "2006-01-02T15:04:05Z"},
+	{"Monday, 02-Jan-06 15:04:05 GMT", "2006-01-02T15:04:05Z"},
+	{"Saturday, 11-Mar-89 07:23:10 EST", "1989-03-11T07:23:10-05:00"},
+	{"Thu, 4 Jan 90 11:00:00 PST", "1990-01-04T11:00:00-08:00"},
+	{"Mon Jan  2 15:04:05 2006", "2006-01-02T15:04:05Z"},
+	{"mon, 02 jan 2006 15:04:05 +0100", "2006-01-02T15:04:05+01:00"},
+	{"MON, 02 JAN 2006 15:04:05 CEST", "2006-01-02T15:04:05+02:00"},
+	{"02 January 2006 15:04:05 UT", "2006-01-02T15:04:05Z"},
+	{"Mon, 02 Jan 2006 15:04:05 -0700 (MST)", "2006-01-02T15:04:05-07:00"},
+	{"Mon, 02 Jan 2006 15:04:05 (Pacific Daylight Time) -0700", "2006-01-02T15:04:05-07:00"},
+	{"Mon, 02 Jan 2006 15:04:05 (EST)", "2006-01-02T15:04:05-05:00"},
+	{"Mon,  02  Jan  2006  15:04:05  +0000", "2006-01-02T15:04:05Z"},
+	{"Tue, 3 Feb 2009 01:02:03 +0530", "2009-02-03T01:02:03+05:30"},
+	{"Tue, 3 Feb 2009 01:02:03 GMT+1", "2009-02-03T01:02:03+01:00"},
+	{"Tue, 3 Feb 2009 01:02:03 +05:30", "2009-02-03T01:02:03+05:30"},
+	{"Tue, 3 Feb 2009 01:02:03", "2009-02-03T01:02:03Z"},
+	{"Tue, 3 Feb 2009 1:02 PM EST", "2009-02-03T13:02:00-05:00"},
+	{"31 Dec 49 23:59:59 GMT", "2049-12-31T23:59:59Z"},
+	{"1 Jan 50 00:00:00 GMT", "1950-01-01T00:00:00Z"},
+	{"1 Jan 102 00:00:00 GMT", "2002-01-01T00:00:00Z"},
+	{"31 Dec 1998 23:59:60 GMT", "1998-12-31T23:59:59Z"},
+	{"2006-01-02T15:04:05Z", "2006-01-02T15:04:05Z"},
+	{"2006-01-02T15:04:05.5+01:00", "2006-01-02T15:04:05.5+01:00"},
+	{"2006-01-02 15:04:05 -0700", "2006-01-02T15:04:05-07:00"},
+	{"2006-01-02", "2006-01-02T00:00:00Z"},
+	{"Sun, 5 Sept 2010 10:00:00 -0400", "2010-09-05T10:00:00-04:00"},
+}
+
+func TestParseDate(t *testing.T) {
+	for _, tt := range dateCorpus {
+		got, err := ParseDate(tt.in)
+		if err != nil {
+			t.Errorf("ParseDate(%q): %v", tt.in, err)
+			continue
+		}
+		want, _ := time.Parse(time.RFC3339Nano, tt.want)
+		if !got.Equal(want) {
+			t.Errorf("ParseDate(%q) = %v, want %v", tt.in, got, want)
+		}
+	}
+	for _, in := range []string{
+		"",
+		"yesterday",
+		"Mon, 31 Feb 2006 15:04:05 GMT",
+		"Mon, 02 Jan 2006 25:04:05 GMT",
+		"Mon, 02 Jan 2006 15:61:05 GMT",
+		"Mon, 02 Foo 2006 15:04:05 GMT",
+		"Mon, 02 Jan 2006 15:04:05 XYZ",
+		"2006-13-02T15:04:05Z",
+	} {
+		if got, err := ParseDate(in); err == nil {
+			t.Errorf("ParseDate(%q) = %v, want error", in, got)
+		}
+	}
+}
+
+// FuzzParseDate checks that ParseDate never panics and that whatever it
+// accepts survives a round trip through RFC 1123 form.
+func FuzzParseDate(f *testing.F) {
+	for _, tt := range dateCorpus {
+		f.Add(tt.in)
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		got, err := ParseDate(in)
+		if err != nil || got.Year() < 1000 || got.Year() > 9999 {
+			return
+		}
+		s := got.Format(time.RFC1123Z)
+		again, err := ParseDate(s)
+		if err != nil {
+			t.Fatalf("ParseDate(%q) = %v, but %q does not parse: %v", in, got, s, err)
+		}
+		if !again.Equal(got.Truncate(time.Second)) {
+			t.Fatalf("ParseDate(%q) = %v, but %q parses as %v", in, got, s, again)
+		}
+	})
+}
@@ -0,0 +1,299 @@
+package nntp
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// A DialOption configures how Dial connects.
+type DialOption func(*dialConfig)
+
+type dialConfig struct {
+	dialer net.Dialer
+	tls    *tls.Config
+	proxy  *url.URL
+	dial   func(network, addr string) (net.Conn, error)
+}
+
+// WithTimeout bounds the whole connection setup: dialing, any proxy and
+// TLS handshakes, and reading the server greeting.
+func WithTimeout(d time.Duration) DialOption {
+	return func(c *dialConfig) { c.dialer.Timeout = d }
+}
+
+// WithLocalAddr binds the connection to a local address, e.g. to choose
+// the source IP on a multi-homed host. The port may be zero.
+func WithLocalAddr(addr net.Addr) DialOption {
+	return func(c *dialConfig) { c.dialer.LocalAddr = addr }
+}
+
+// WithKeepAlive sets the TCP keep-alive period. A negative value disables
+// keep-alives.
+func WithKeepAlive(d time.Duration) DialOption {
+	return func(c *dialConfig) { c.dialer.KeepAlive = d }
+}
+
+// WithTLS makes the connection use TLS. If cfg.ServerName is empty it is
+// taken from the address being dialed. cfg may be nil.
+func WithTLS(cfg *tls.Config) DialOption {
+	return func(c *dialConfig) {
+		if cfg == nil {
+			cfg = &tls.Config{}
+		}
+		c.tls = cfg
+	}
+}
+
+// WithProxy routes the connection through a proxy given as a URL:
+// "socks5://[user:pass@]host:port" or "http://[user:pass@]host:port" for
+// an HTTP CONNECT proxy. The server address is resolved by the proxy.
+func WithProxy(u *url.URL) DialOption {
+	return func(c *dialConfig) { c.proxy = u }
+}
+
+// WithDialFunc replaces the function used to open the TCP connection (to
+// the proxy, if one is set). The other socket options are then ignored.
+func WithDialFunc(dial func(network, addr string) (net.Conn, error)) DialOption {
+	return func(c *dialConfig) { c.dial = dial }
+}
+
+// Dial connects to an NNTP server with the given options and reads its
+// greeting.
+//
+// Example:
+//   conn, err := nntp.Dial("tcp", "news.example.com:563",
+//       nntp.WithTLS(nil), nntp.WithTimeout(10*time.Second))
+//
+func Dial(network, addr string, opts ...DialOption) (*Conn, error) {
+	cfg := &dialConfig{}
+	for _, o := range opts {
+		o(cfg)
+	}
+	var deadline time.Time
+	if cfg.dialer.Timeout > 0 {
+		deadline = time.Now().Add(cfg.dialer.Timeout)
+	}
+
+	conn, err := cfg.dialTransport(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if !deadline.IsZero() {
+		conn.SetDeadline(deadline)
+	}
+	if cfg.proxy != nil {
+		if err = dialProxy(conn, cfg.proxy, addr); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if cfg.tls != nil {
+		tcfg := cfg.tls
+		if tcfg.ServerName == "" {
+			tcfg = tcfg.Clone()
+			tcfg.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tc := tls.Client(conn, tcfg)
+		if err = tc.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tc
+	}
+	c, err := NewClient(conn)
+	if err != nil {
+		return nil, err
+	}
+	if !deadline.IsZero() {
+		conn.SetDeadline(time.Time{})
+	}
+	return c, nil
+}
+
+func (cfg *dialConfig) dialTransport(network, addr string) (net.Conn, error) {
+	if cfg.proxy != nil {
+		addr = cfg.proxy.Host
+		if cfg.proxy.Port() == "" {
+			port := "1080"
+			if cfg.proxy.Scheme == "http" {
+				port = "8080"
+			}
+			addr = net.JoinHostPort(cfg.proxy.Hostname(), port)
+		}
+	}
+	if cfg.dial != nil {
+		return cfg.dial(network, addr)
+	}
+	return cfg.dialer.Dial(network, addr)
+}
+
+// NewClient runs the NNTP greeting over an established connection, which
+// may be any transport: a proxied or TLS connection, an SSH channel, or
+// one end of net.Pipe in tests. The Conn takes ownership of conn.
+func NewClient(conn net.Conn) (*Conn, error) {
+	return newClient(conn)
+}
+
+// A ProxyError reports a proxy refusing or failing the connection.
+type ProxyError string
+
+func (p ProxyError) Error() string {
+	return "nntp: proxy: " + string(p)
+}
+
+func dialProxy(conn net.Conn, u *url.URL, addr string) error {
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		return socks5Connect(conn, u, addr)
+	case "http":
+		return httpConnect(conn, u, addr)
+	}
+	return ProxyError("unsupported scheme " + u.Scheme)
+}
+
+// httpConnect asks an HTTP proxy for a tunnel with the CONNECT method.
+func httpConnect(conn net.Conn, u *url.URL, addr string) error {
+	req := "CONNECT " + addr + " HTTP/1.1\r\nHost: " + addr + "\r\n"
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + pass))
+		req += "Proxy-Authorization: Basic " + auth + "\r\n"
+	}
+	if _, err := io.WriteString(conn, req+"\r\n"); err != nil {
+		return err
+	}
+	// Read the response byte by byte so nothing after it is consumed.
+	resp, err := http.ReadResponse(bufio.NewReaderSize(byteReader{conn}, 16), nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ProxyError(resp.Status)
+	}
+	return nil
+}
+
+type byteReader struct {
+	r io.Reader
+}
+
+func (b byteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return b.r.Read(p)
+}
+
+var socks5Errors = []string{
+	1: "general failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// socks5Connect performs a SOCKS5 CONNECT (RFC 1928), with
+// username/password authentication (RFC 1929) if u has credentials.
+func socks5Connect(conn net.Conn, u *url.URL, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 0xffff {
+		return errors.New("nntp: bad port " + portStr)
+	}
+	if len(host) > 255 {
+		return errors.New("nntp: host name too long")
+	}
+
+	methods := []byte{0}
+	if u.User != nil {
+		methods = []byte{2}
+	}
+	if _, err = conn.Write(append([]byte{5, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	var buf [2]byte
+	if _, err = io.ReadFull(conn, buf[:]); err != nil {
+		return err
+	}
+	if buf[0] != 5 || buf[1] != methods[0] {
+		return ProxyError("no acceptable authentication method")
+	}
+	if buf[1] == 2 {
+		user := u.User.Username()
+		pass, _ := u.User.Password()
+		if len(user) > 255 || len(pass) > 255 {
+			return errors.New("nntp: proxy credentials too long")
+		}
+		req := []byte{1, byte(len(user))}
+		req = append(req, user...)
+		req = append(req, byte(len(pass)))
+		req = append(req, pass...)
+		if _, err = conn.Write(req); err != nil {
+			return err
+		}
+		if _, err = io.ReadFull(conn, buf[:]); err != nil {
+			return err
+		}
+		if buf[1] != 0 {
+			return ProxyError("authentication failed")
+		}
+	}
+
+	req := []byte{5, 1, 0}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+		req = append(append(req, 1), ip.To4()...)
+	} else if ip != nil {
+		req = append(append(req, 4), ip.To16()...)
+	} else {
+		req = append(append(req, 3, byte(len(host))), host...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	if _, err = conn.Write(req); err != nil {
+		return err
+	}
+
+	var hdr [4]byte
+	if _, err = io.ReadFull(conn, hdr[:]); err != nil {
+		return err
+	}
+	if hdr[1] != 0 {
+		msg := fmt.Sprintf("error %d", hdr[1])
+		if int(hdr[1]) < len(socks5Errors) {
+			msg = socks5Errors[hdr[1]]
+		}
+		return ProxyError(msg)
+	}
+	// Skip the bound address.
+	var skip int
+	switch hdr[3] {
+	case 1:
+		skip = 4
+	case 4:
+		skip = 16
+	case 3:
+		if _, err = io.ReadFull(conn, buf[:1]); err != nil {
+			return err
+		}
+		skip = int(buf[0])
+	default:
+		return ProxyError("bad address type in reply")
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
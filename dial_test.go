@@ -0,0 +1,124 @@
+package nntp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// serve accepts one connection on a loopback listener and runs fn on it.
+func serve(t *testing.T, fn func(c net.Conn)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen on loopback:", err)
+	}
+	go func() {
+		defer l.Close()
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		fn(c)
+	}()
+	return l.Addr().String()
+}
+
+func TestNewClientPipe(t *testing.T) {
+	client, server := net.Pipe()
+	go func() {
+		io.WriteString(server, "200 over a pipe\r\n")
+		bufio.NewReader(server).ReadString('\n')
+		server.Close()
+	}()
+	conn, err := NewClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.conn.Close()
+	if conn.Banner != "over a pipe" {
+		t.Errorf("banner %q", conn.Banner)
+	}
+}
+
+func TestDialTimeout(t *testing.T) {
+	addr := serve(t, func(c net.Conn) {
+		time.Sleep(time.Second) // never greet
+	})
+	start := time.Now()
+	_, err := Dial("tcp", addr, WithTimeout(100*time.Millisecond))
+	if err == nil {
+		t.Fatal("expected timeout")
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("timeout took %v", d)
+	}
+}
+
+func TestDialSOCKS5(t *testing.T) {
+	got := make(chan string, 1)
+	addr := serve(t, func(c net.Conn) {
+		br := bufio.NewReader(c)
+		read := func(n int) []byte {
+			b := make([]byte, n)
+			io.ReadFull(br, b)
+			return b
+		}
+		read(3) // version, 1 method, user/pass
+		c.Write([]byte{5, 2})
+		read(1)
+		user := read(int(read(1)[0]))
+		pass := read(int(read(1)[0]))
+		c.Write([]byte{1, 0})
+		read(4) // version, CONNECT, reserved, domain name
+		host := read(int(read(1)[0]))
+		port := read(2)
+		got <- fmt.Sprintf("%s:%s@%s:%d", user, pass, host, int(port[0])<<8|int(port[1]))
+		c.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+		io.WriteString(c, "200 via socks\r\n")
+		br.ReadString('\n')
+	})
+	u, _ := url.Parse("socks5://bob:pw@" + addr)
+	conn, err := Dial("tcp", "news.example.com:119", WithProxy(u), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.conn.Close()
+	if conn.Banner != "via socks" {
+		t.Errorf("banner %q", conn.Banner)
+	}
+	if s := <-got; s != "bob:pw@news.example.com:119" {
+		t.Errorf("proxy saw %q", s)
+	}
+}
+
+func TestDialHTTPConnect(t *testing.T) {
+	got := make(chan *http.Request, 1)
+	addr := serve(t, func(c net.Conn) {
+		br := bufio.NewReader(c)
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+		got <- req
+		io.WriteString(c, "HTTP/1.1 200 Connection established\r\n\r\n200 via http\r\n")
+		br.ReadString('\n')
+	})
+	u, _ := url.Parse("http://" + addr)
+	conn, err := Dial("tcp", "news.example.com:119", WithProxy(u), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.Banner != "via http" {
+		t.Errorf("banner %q", conn.Banner)
+	}
+	defer conn.conn.Close()
+	if req := <-got; req.Method != "CONNECT" || req.Host != "news.example.com:119" {
+		t.Errorf("proxy got %s %s", req.Method, req.Host)
+	}
+}
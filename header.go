@@ -0,0 +1,301 @@
+package nntp
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// A HeaderField is a single header of an article as it was received.
+type HeaderField struct {
+	// Name as written, without canonicalizing its case.
+	Name string
+	// Value with folding removed and surrounding white space trimmed.
+	Value string
+	// Raw holds the field exactly as read, including continuation lines
+	// and line endings. It is nil for fields added with Add or Set.
+	Raw []byte
+}
+
+// An OrderedHeader keeps the fields of an article header in their original
+// order, case and folding, so that an article can be written back out
+// unchanged. Lines that are not valid header fields are kept with an empty
+// Name so they too survive a round trip.
+type OrderedHeader []HeaderField
+
+// ReadOrderedHeader reads a header block from r up to and including the
+// blank line that ends it. Line endings are preserved as found.
+func ReadOrderedHeader(r *bufio.Reader) (OrderedHeader, error) {
+	h, _, err := readOrderedHeader(func() (string, error) {
+		line, err := r.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return line, err
+	}, false)
+	return h, err
+}
+
+// readWireHeader reads a header from a dot-encoded NNTP response. The
+// returned bool reports that the terminating "." was reached, meaning the
+// article has no body. Lines are stored with CRLF endings, the canonical
+// form on the wire.
+func readWireHeader(r *textproto.Reader) (OrderedHeader, bool, error) {
+	return readOrderedHeader(func() (string, error) {
+		line, err := r.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		return line + "\r\n", nil
+	}, true)
+}
+
+func readOrderedHeader(next func() (string, error), dotted bool) (OrderedHeader, bool, error) {
+	var h OrderedHeader
+	for {
+		line, err := next()
+		if err != nil {
+			if err == io.EOF && len(h) > 0 && !dotted {
+				return h, false, nil
+			}
+			return h, false, err
+		}
+		text := strings.TrimRight(line, "\r\n")
+		if dotted && strings.HasPrefix(text, ".") {
+			if text == "." {
+				return h, true, nil
+			}
+			line, text = line[1:], text[1:]
+		}
+		if text == "" {
+			return h, false, nil
+		}
+		if (text[0] == ' ' || text[0] == '\t') && len(h) > 0 {
+			f := &h[len(h)-1]
+			f.Raw = append(f.Raw, line...)
+			if v := strings.TrimSpace(text); v != "" {
+				if f.Value != "" {
+					f.Value += " "
+				}
+				f.Value += v
+			}
+			continue
+		}
+		f := HeaderField{Raw: []byte(line)}
+		if i := strings.IndexByte(text, ':'); i > 0 && !strings.ContainsAny(text[:i], " \t") {
+			f.Name = text[:i]
+			f.Value = strings.TrimSpace(text[i+1:])
+		}
+		h = append(h, f)
+	}
+}
+
+// Get returns the first value of the named field, ignoring case, or "".
+func (h OrderedHeader) Get(name string) string {
+	for _, f := range h {
+		if f.Name != "" && strings.EqualFold(f.Name, name) {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// Values returns all values of the named field in order.
+func (h OrderedHeader) Values(name string) []string {
+	var res []string
+	for _, f := range h {
+		if f.Name != "" && strings.EqualFold(f.Name, name) {
+			res = append(res, f.Value)
+		}
+	}
+	return res
+}
+
+// Add appends a field.
+func (h *OrderedHeader) Add(name, value string) {
+	*h = append(*h, HeaderField{Name: name, Value: value})
+}
+
+// Set replaces the first field with the given name, keeping its position,
+// and removes any others. The field is appended if not present.
+func (h *OrderedHeader) Set(name, value string) {
+	res := (*h)[:0]
+	found := false
+	for _, f := range *h {
+		if f.Name != "" && strings.EqualFold(f.Name, name) {
+			if found {
+				continue
+			}
+			f = HeaderField{Name: f.Name, Value: value}
+			found = true
+		}
+		res = append(res, f)
+	}
+	*h = res
+	if !found {
+		h.Add(name, value)
+	}
+}
+
+// Del removes all fields with the given name.
+func (h *OrderedHeader) Del(name string) {
+	res := (*h)[:0]
+	for _, f := range *h {
+		if f.Name == "" || !strings.EqualFold(f.Name, name) {
+			res = append(res, f)
+		}
+	}
+	*h = res
+}
+
+// MIMEHeader returns the fields as a map keyed by canonical name, as
+// textproto.Reader.ReadMIMEHeader would.
+func (h OrderedHeader) MIMEHeader() textproto.MIMEHeader {
+	m := textproto.MIMEHeader{}
+	for _, f := range h {
+		if f.Name != "" {
+			m.Add(f.Name, f.Value)
+		}
+	}
+	return m
+}
+
+// WriteTo writes the header fields to w. Fields that were read keep their
+// exact original bytes; added fields are written as "Name: Value" lines
+// ending in CRLF. The blank line ending the header is not written.
+func (h OrderedHeader) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for _, f := range h {
+		var n int
+		var err error
+		if f.Raw != nil {
+			n, err = w.Write(f.Raw)
+		} else {
+			n, err = io.WriteString(w, f.Name+": "+f.Value+"\r\n")
+		}
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// HeaderValue returns the first value of the named field, ignoring case,
+// or "". It reads Fields if set and Header otherwise.
+func (a *Article) HeaderValue(name string) string {
+	if a.Fields != nil {
+		return a.Fields.Get(name)
+	}
+	for k, vs := range a.Header {
+		if strings.EqualFold(k, name) && len(vs) > 0 {
+			return vs[0]
+		}
+	}
+	return ""
+}
+
+// delMapHeader removes a field from a header map, whatever the case of
+// its key.
+func delMapHeader(m map[string][]string, name string) {
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			delete(m, k)
+		}
+	}
+}
+
+// SetHeader replaces the named field in both Header and Fields. In Fields
+// it keeps the position of the first occurrence.
+func (a *Article) SetHeader(name, value string) {
+	if a.Fields != nil {
+		a.Fields.Set(name, value)
+	}
+	if a.Header == nil {
+		a.Header = map[string][]string{}
+	}
+	delMapHeader(a.Header, name)
+	a.Header[textproto.CanonicalMIMEHeaderKey(name)] = []string{value}
+}
+
+// AddHeader appends a field to both Header and Fields.
+func (a *Article) AddHeader(name, value string) {
+	if a.Fields != nil {
+		a.Fields.Add(name, value)
+	}
+	if a.Header == nil {
+		a.Header = map[string][]string{}
+	}
+	textproto.MIMEHeader(a.Header).Add(name, value)
+}
+
+// DelHeader removes the named field from both Header and Fields.
+func (a *Article) DelHeader(name string) {
+	if a.Fields != nil {
+		a.Fields.Del(name)
+	}
+	delMapHeader(a.Header, name)
+}
+
+// headerFromMap builds an OrderedHeader from a map, sorted by name, for
+// articles that were not read from the network.
+func headerFromMap(m map[string][]string) OrderedHeader {
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var h OrderedHeader
+	for _, k := range names {
+		for _, v := range m[k] {
+			h.Add(k, v)
+		}
+	}
+	return h
+}
+
+// WriteTo writes the article in wire format without dot-stuffing: the
+// header, a blank line and the body, with CRLF line endings. An article
+// read from the server is reproduced byte for byte.
+func (a *Article) WriteTo(w io.Writer) (int64, error) {
+	h := a.Fields
+	if h == nil {
+		h = headerFromMap(a.Header)
+	}
+	var buf bytes.Buffer
+	h.WriteTo(&buf)
+	buf.WriteString("\r\n")
+	for _, line := range a.Body {
+		buf.WriteString(line)
+		buf.WriteString("\r\n")
+	}
+	return buf.WriteTo(w)
+}
+
+// ReadArticle reads an article in the form written by Article.WriteTo: a
+// header, a blank line and the body, not dot-stuffed. Body lines may end
+// in CRLF or LF.
+func ReadArticle(r io.Reader) (*Article, error) {
+	br := bufio.NewReader(r)
+	h, err := ReadOrderedHeader(br)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	a := &Article{Header: h.MIMEHeader(), Fields: h}
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+			a.Body = append(a.Body, line)
+		}
+		if err == io.EOF {
+			return a, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package nntp
+
+import (
+	"bufio"
+	"bytes"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+const rawArticle = "Path: a!b\r\n" +
+	"From: Someone <s@example.com>\r\n" +
+	"Newsgroups: x.test,\r\n" +
+	"\ty.test\r\n" +
+	"X-Thing: 1\r\n" +
+	"subject: mixed case\r\n" +
+	"X-Thing: 2\r\n" +
+	"not a header\r\n" +
+	"\r\n" +
+	"Body line\r\n" +
+	"..leading dot\r\n" +
+	".\r\n"
+
+func TestArticleRoundTrip(t *testing.T) {
+	var cmdbuf bytes.Buffer
+	server := "220 1 <a@b> article\r\n" + rawArticle
+	conn := &Conn{conn: textproto.NewConn(faker{&cmdbuf, bufio.NewReader(strings.NewReader(server))})}
+	a, err := conn.Article("<a@b>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := a.Fields
+	if got := h.Get("NEWSGROUPS"); got != "x.test, y.test" {
+		t.Errorf("unfolded Newsgroups = %q", got)
+	}
+	if got := h.Values("x-thing"); len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Errorf("X-Thing values = %v", got)
+	}
+	if h[4].Name != "subject" {
+		t.Errorf("case not preserved: %q", h[4].Name)
+	}
+	if a.Header["Subject"][0] != "mixed case" {
+		t.Errorf("map header not populated: %v", a.Header)
+	}
+
+	var out bytes.Buffer
+	a.WriteTo(&out)
+	want := strings.TrimSuffix(strings.Replace(rawArticle, "\r\n..", "\r\n.", 1), ".\r\n")
+	if out.String() != want {
+		t.Errorf("round trip mismatch:\n%q\n%q", out.String(), want)
+	}
+}
+
+func TestOrderedHeaderEdit(t *testing.T) {
+	h, err := ReadOrderedHeader(bufio.NewReader(strings.NewReader("A: 1\nB: 2\nA: 3\n\nbody")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Set("a", "x")
+	h.Add("C", "4")
+	h.Del("b")
+	var out bytes.Buffer
+	h.WriteTo(&out)
+	if got := out.String(); got != "A: x\r\nC: 4\r\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestArticleHeaderEdit(t *testing.T) {
+	a, err := ReadArticle(strings.NewReader("Subject: old\r\nXref: host a:1\r\nX-Y: 1\r\n\r\nbody\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.SetHeader("subject", "new")
+	a.DelHeader("XREF")
+	a.AddHeader("X-Y", "2")
+	var out bytes.Buffer
+	a.WriteTo(&out)
+	if got := out.String(); got != "Subject: new\r\nX-Y: 1\r\nX-Y: 2\r\n\r\nbody\r\n" {
+		t.Errorf("written: %q", got)
+	}
+	if a.Header["Subject"][0] != "new" || a.Header["Xref"] != nil || len(a.Header["X-Y"]) != 2 {
+		t.Errorf("Header out of step: %v", a.Header)
+	}
+}
+
+func TestHeaderValue(t *testing.T) {
+	a := &Article{Header: map[string][]string{"message-id": {"<a@b>"}}}
+	if got := a.HeaderValue("Message-ID"); got != "<a@b>" {
+		t.Errorf("HeaderValue from Header = %q", got)
+	}
+	a.Fields = OrderedHeader{{Name: "Message-ID", Value: "<c@d>"}}
+	if got := a.HeaderValue("message-id"); got != "<c@d>" {
+		t.Errorf("HeaderValue from Fields = %q", got)
+	}
+}
@@ -0,0 +1,117 @@
+package nntp
+
+import (
+	"strings"
+	"time"
+)
+
+// A CommandEvent describes one command and its response, for Hooks.
+type CommandEvent struct {
+	// Command is the upper-cased command verb, e.g. "ARTICLE".
+	Command string
+	// Line is the full command line with credentials redacted.
+	Line  string
+	Start time.Time
+	// The fields below are set before AfterCommand is called.
+	Duration time.Duration
+	Code     int
+	Err      error
+	// Bytes written and read on the wire for the command, including any
+	// multi-line data. Zero for connections not made by this package.
+	BytesSent     int64
+	BytesReceived int64
+	// Compressed is set for responses that were compressed on the wire,
+	// in which case DecodedBytes is the size after decompression.
+	Compressed   bool
+	DecodedBytes int64
+	// Value is left for hooks to carry state from BeforeCommand to
+	// AfterCommand, such as a tracing span.
+	Value interface{}
+}
+
+// Hooks observes the commands issued on a Conn. Both methods are called
+// synchronously and should return quickly.
+type Hooks interface {
+	BeforeCommand(ev *CommandEvent)
+	AfterCommand(ev *CommandEvent)
+}
+
+// SetHooks installs h to observe every command on the connection. Use
+// MultiHooks to install several.
+func (c *Conn) SetHooks(h Hooks) {
+	c.hooks = h
+}
+
+type multiHooks []Hooks
+
+// MultiHooks returns Hooks that calls each of hs in order.
+func MultiHooks(hs ...Hooks) Hooks {
+	return multiHooks(hs)
+}
+
+func (m multiHooks) BeforeCommand(ev *CommandEvent) {
+	for _, h := range m {
+		h.BeforeCommand(ev)
+	}
+}
+
+func (m multiHooks) AfterCommand(ev *CommandEvent) {
+	for _, h := range m {
+		h.AfterCommand(ev)
+	}
+}
+
+// begin starts tracking a command. The event must be completed with end
+// once the whole response has been read.
+func (c *Conn) begin(cmd string) *CommandEvent {
+	c.limits.Commands.Wait()
+	line := redact(cmd)
+	verb := line
+	if i := strings.IndexByte(verb, ' '); i >= 0 {
+		verb = verb[:i]
+	}
+	ev := &CommandEvent{
+		Command: strings.ToUpper(verb),
+		Line:    line,
+		Start:   time.Now(),
+	}
+	if c.wire != nil {
+		ev.BytesSent, ev.BytesReceived = -c.wire.written, -c.consumed()
+	}
+	if c.hooks != nil {
+		c.hooks.BeforeCommand(ev)
+	}
+	return ev
+}
+
+// exec sends the command and reads the status line, recording the code
+// in ev.
+func (c *Conn) exec(ev *CommandEvent, cmd string, expectCode int) (int, string, error) {
+	c.debugf("client: %s", redact(cmd))
+	err := c.conn.PrintfLine("%s", cmd)
+	if err != nil {
+		return 0, "", err
+	}
+	code, msg, err := c.conn.ReadCodeLine(expectCode)
+	c.debugf("server code: %d, msg: %s, err: %v", code, msg, err)
+	ev.Code = code
+	return code, msg, err
+}
+
+// consumed returns the number of bytes read from the wire and used,
+// excluding read-ahead still sitting in the buffer.
+func (c *Conn) consumed() int64 {
+	return c.wire.read - int64(c.conn.R.Buffered())
+}
+
+func (c *Conn) end(ev *CommandEvent, err error) {
+	ev.Duration = time.Since(ev.Start)
+	ev.Err = err
+	if c.wire != nil {
+		ev.BytesSent += c.wire.written
+		ev.BytesReceived += c.consumed()
+	}
+	if c.hooks != nil {
+		c.hooks.AfterCommand(ev)
+	}
+}
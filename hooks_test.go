@@ -0,0 +1,55 @@
+package nntp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type recordHooks struct {
+	before, after []CommandEvent
+}
+
+func (r *recordHooks) BeforeCommand(ev *CommandEvent) { r.before = append(r.before, *ev) }
+func (r *recordHooks) AfterCommand(ev *CommandEvent)  { r.after = append(r.after, *ev) }
+
+func TestHooks(t *testing.T) {
+	server := "200 ready\r\n" +
+		"220 1 <a@b> article\r\nSubject: x\r\n\r\nbody\r\n.\r\n" +
+		"430 no such article\r\n"
+	var cmdbuf bytes.Buffer
+	conn, err := newClient(faker{&cmdbuf, bufio.NewReader(strings.NewReader(server))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &recordHooks{}
+	conn.SetHooks(MultiHooks(rec))
+
+	if _, err := conn.Article("<a@b>"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Head("<missing@b>")
+	if err == nil {
+		t.Fatal("expected error for missing article")
+	}
+
+	if len(rec.before) != 2 || len(rec.after) != 2 {
+		t.Fatalf("got %d before and %d after events", len(rec.before), len(rec.after))
+	}
+	ev := rec.after[0]
+	if ev.Command != "ARTICLE" || ev.Line != "ARTICLE <a@b>" || ev.Code != 220 || ev.Err != nil {
+		t.Errorf("unexpected event %+v", ev)
+	}
+	if ev.BytesSent != int64(len("ARTICLE <a@b>\r\n")) {
+		t.Errorf("BytesSent = %d", ev.BytesSent)
+	}
+	if ev.BytesReceived <= 0 {
+		t.Errorf("BytesReceived = %d", ev.BytesReceived)
+	}
+	ev = rec.after[1]
+	if ev.Command != "HEAD" || ev.Code != 430 || !errors.Is(ev.Err, err) {
+		t.Errorf("unexpected event %+v", ev)
+	}
+}
@@ -0,0 +1,138 @@
+package nntp
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A Logger receives diagnostic output from a Conn. *logrus.Logger and
+// *logrus.Entry satisfy it; other loggers need a one-method adapter.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+}
+
+// SetLogger sets the logger for commands and responses on this
+// connection. Connections made by this package start out logging to the
+// standard logrus logger; a nil Logger disables logging.
+func (c *Conn) SetLogger(l Logger) {
+	c.logger = l
+}
+
+// SetTrace turns wire tracing on or off. While enabled, every line sent
+// or received is logged, including article data. Credentials are redacted
+// as in regular logging. Tracing happens below the connection's read
+// buffer, so data already buffered when it is enabled is not shown. It
+// only covers connections made by this package.
+func (c *Conn) SetTrace(on bool) {
+	c.trace = on
+}
+
+func (c *Conn) debugf(format string, args ...interface{}) {
+	if c.logger != nil {
+		c.logger.Debugf(format, args...)
+	}
+}
+
+// redact hides the secret part of AUTHINFO commands.
+func redact(cmd string) string {
+	f := strings.Fields(cmd)
+	if len(f) < 3 || !strings.EqualFold(f[0], "AUTHINFO") {
+		return cmd
+	}
+	switch strings.ToUpper(f[1]) {
+	case "PASS":
+		return f[0] + " " + f[1] + " ********"
+	case "SASL":
+		// Keep the mechanism name, hide any initial response.
+		if len(f) > 3 {
+			return strings.Join(f[:3], " ") + " ********"
+		}
+	}
+	return cmd
+}
+
+// wireConn wraps the transport of a Conn. It counts the bytes that pass
+// through it, applies bandwidth limits and, while tracing is enabled, logs
+// each complete line.
+type wireConn struct {
+	io.ReadWriteCloser
+	c             *Conn
+	read, written int64
+	rbuf, wbuf    []byte
+}
+
+func (t *wireConn) Read(p []byte) (int, error) {
+	lim := t.c.limits.Download
+	if lim != nil && len(p) > lim.Burst() {
+		p = p[:lim.Burst()]
+	}
+	n, err := t.ReadWriteCloser.Read(p)
+	lim.WaitN(n)
+	t.read += int64(n)
+	if t.c.trace {
+		t.rbuf = t.emit(t.rbuf, p[:n], "S: ")
+	}
+	return n, err
+}
+
+func (t *wireConn) Write(p []byte) (int, error) {
+	n, err := t.write(p)
+	t.written += int64(n)
+	if t.c.trace {
+		t.wbuf = t.emit(t.wbuf, p[:n], "C: ")
+	}
+	return n, err
+}
+
+// write sends p in pieces no larger than the upload burst, waiting for
+// each to be allowed.
+func (t *wireConn) write(p []byte) (int, error) {
+	lim := t.c.limits.Upload
+	if lim == nil {
+		return t.ReadWriteCloser.Write(p)
+	}
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > lim.Burst() {
+			chunk = chunk[:lim.Burst()]
+		}
+		lim.WaitN(len(chunk))
+		n, err := t.ReadWriteCloser.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (t *wireConn) emit(buf, p []byte, prefix string) []byte {
+	buf = append(buf, p...)
+	for {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			return buf
+		}
+		line := strings.TrimRight(string(buf[:i]), "\r")
+		if prefix == "C: " {
+			line = redact(line)
+		}
+		t.c.debugf("%s%s", prefix, printable(line))
+		buf = buf[i+1:]
+	}
+}
+
+// printable quotes lines with binary content, such as compressed
+// overview data, so they do not garble the log.
+func printable(s string) string {
+	if !utf8.ValidString(s) || strings.IndexFunc(s, func(r rune) bool { return !unicode.IsPrint(r) && r != '\t' }) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
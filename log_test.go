@@ -0,0 +1,94 @@
+package nntp
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+type recordLogger struct {
+	lines []string
+}
+
+func (r *recordLogger) Debugf(format string, args ...interface{}) {
+	r.lines = append(r.lines, fmt.Sprintf(format, args...))
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"AUTHINFO USER bob", "AUTHINFO USER bob"},
+		{"AUTHINFO PASS s3cret", "AUTHINFO PASS ********"},
+		{"authinfo pass s3cret", "authinfo pass ********"},
+		{"AUTHINFO SASL PLAIN AGJvYgBzM2NyZXQ=", "AUTHINFO SASL PLAIN ********"},
+		{"GROUP alt.test", "GROUP alt.test"},
+	}
+	for _, tt := range tests {
+		if got := redact(tt.in); got != tt.want {
+			t.Errorf("redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLoggerAndTrace(t *testing.T) {
+	server := "200 ready\r\n381 more\r\n281 ok\r\n100 help\r\nline one\r\n.\r\n"
+	var cmdbuf bytes.Buffer
+	conn, err := newClient(faker{&cmdbuf, iotest.OneByteReader(strings.NewReader(server))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &recordLogger{}
+	conn.SetLogger(rec)
+	if err := conn.Authenticate("bob", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range rec.lines {
+		if strings.Contains(l, "s3cret") {
+			t.Errorf("password logged: %q", l)
+		}
+	}
+
+	rec.lines = nil
+	conn.SetTrace(true)
+	if _, err := conn.Help(); err != nil {
+		t.Fatal(err)
+	}
+	log := strings.Join(rec.lines, "\n")
+	for _, want := range []string{"C: HELP", "S: 100 help", "S: line one", "S: ."} {
+		if !strings.Contains(log, want) {
+			t.Errorf("trace missing %q:\n%s", want, log)
+		}
+	}
+
+	rec.lines = nil
+	conn.SetLogger(nil)
+	conn.Quit()
+	if len(rec.lines) != 0 {
+		t.Errorf("nil logger still logged %v", rec.lines)
+	}
+}
+
+func TestLogPostTerminator(t *testing.T) {
+	server := "200 ready\r\n340 send\r\n240 posted\r\n"
+	var cmdbuf bytes.Buffer
+	conn, err := newClient(faker{&cmdbuf, strings.NewReader(server)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &recordLogger{}
+	conn.SetLogger(rec)
+	if err := conn.RawPost(strings.NewReader("Subject: x\r\n\r\nbody\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"client: POST", "client: ."}
+	var got []string
+	for _, l := range rec.lines {
+		if strings.HasPrefix(l, "client: ") {
+			got = append(got, l)
+		}
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("logged commands %q, want %q", got, want)
+	}
+}
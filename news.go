@@ -0,0 +1,151 @@
+package nntp
+
+import (
+	"strings"
+	"time"
+)
+
+// A NewsQuery asks for the articles or groups that arrived on a server
+// since a given time, with NEWNEWS or NEWGROUPS.
+type NewsQuery struct {
+	// Groups is a wildmat selecting groups, such as
+	// "comp.lang.*,!comp.lang.c". NEWNEWS sends it to the server and uses
+	// "*" if it is empty. NEWGROUPS takes no wildmat, so its results are
+	// filtered here instead.
+	Groups string
+	// Since is sent in GMT, whatever its location. To avoid missing
+	// articles through clock skew, take it from the server's Date.
+	Since time.Time
+	// Distributions limits the results to those distributions. It is an
+	// RFC 977 argument that RFC 3977 dropped; servers that do not support
+	// it may reject the command.
+	Distributions []string
+}
+
+// args returns the arguments of the command, after the wildmat if any.
+func (q NewsQuery) args() (string, error) {
+	args := q.Since.UTC().Format(timeFormatNew) + " GMT"
+	if len(q.Distributions) > 0 {
+		for _, d := range q.Distributions {
+			if d == "" || strings.ContainsAny(d, " \t,<>") {
+				return "", ProtocolError("invalid distribution: " + d)
+			}
+		}
+		args += " <" + strings.Join(q.Distributions, ",") + ">"
+	}
+	return args, nil
+}
+
+// wildmat returns the compiled Groups, or nil if it is empty.
+func (q NewsQuery) wildmat() (*Wildmat, error) {
+	if q.Groups == "" {
+		return nil, nil
+	}
+	if strings.ContainsAny(q.Groups, " \t\r\n") {
+		return nil, ProtocolError("wildmat contains white space: " + q.Groups)
+	}
+	return CompileWildmat(q.Groups)
+}
+
+// QueryNewNews sends NEWNEWS and calls fn with each message-id as it
+// arrives, so that huge responses need not be held in memory. The same
+// article may be listed more than once. If fn returns an error the rest
+// of the response is read and discarded, and that error is returned.
+func (c *Conn) QueryNewNews(q NewsQuery, fn func(msgid string) error) (err error) {
+	if _, err := q.wildmat(); err != nil {
+		return err
+	}
+	args, err := q.args()
+	if err != nil {
+		return err
+	}
+	groups := q.Groups
+	if groups == "" {
+		groups = "*"
+	}
+	cmd := "NEWNEWS " + groups + " " + args
+	ev := c.begin(cmd)
+	defer func() { c.end(ev, err) }()
+	if _, _, err = c.exec(ev, cmd, 230); err != nil {
+		return err
+	}
+	var fnErr error
+	for {
+		line, err := c.conn.ReadLine()
+		if err != nil {
+			return err
+		}
+		if line == "." {
+			break
+		}
+		if strings.HasPrefix(line, "..") {
+			line = line[1:]
+		}
+		line = strings.TrimSpace(line)
+		if fnErr == nil && line != "" {
+			fnErr = fn(line)
+		}
+	}
+	return fnErr
+}
+
+// QueryNewGroups sends NEWGROUPS and returns the groups that match
+// q.Groups.
+func (c *Conn) QueryNewGroups(q NewsQuery) (groups []*Group, err error) {
+	w, err := q.wildmat()
+	if err != nil {
+		return nil, err
+	}
+	args, err := q.args()
+	if err != nil {
+		return nil, err
+	}
+	cmd := "NEWGROUPS " + args
+	ev := c.begin(cmd)
+	defer func() { c.end(ev, err) }()
+	if _, _, err = c.exec(ev, cmd, 231); err != nil {
+		return nil, err
+	}
+	lines, err := c.conn.ReadDotLines()
+	if err != nil {
+		return nil, err
+	}
+	all, err := parseNewGroups(lines)
+	if err != nil || w == nil {
+		return all, err
+	}
+	groups = []*Group{}
+	for _, g := range all {
+		if w.Match(g.Name) {
+			groups = append(groups, g)
+		}
+	}
+	return groups, nil
+}
+
+// QueryNewNews is like Conn.QueryNewNews, retried after a lost
+// connection. Message-ids already passed to fn before the connection was
+// lost are not passed again.
+func (s *Session) QueryNewNews(q NewsQuery, fn func(msgid string) error) error {
+	seen := map[string]bool{}
+	return s.do(true, func(c *Conn) error {
+		return c.QueryNewNews(q, func(id string) error {
+			if seen[id] {
+				return nil
+			}
+			seen[id] = true
+			return fn(id)
+		})
+	})
+}
+
+// QueryNewGroups is like Conn.QueryNewGroups, retried after a lost
+// connection.
+func (s *Session) QueryNewGroups(q NewsQuery) ([]*Group, error) {
+	var groups []*Group
+	err := s.do(true, func(c *Conn) (err error) {
+		groups, err = c.QueryNewGroups(q)
+		return err
+	})
+	return groups, err
+}
@@ -0,0 +1,74 @@
+package nntp
+
+import (
+	"bytes"
+	"errors"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryNewNews(t *testing.T) {
+	server := "230 list follows\r\n" +
+		"<a@x>\r\n" +
+		"..<dot@x>\r\n" +
+		"<b@x>\r\n" +
+		".\r\n" +
+		"230 list follows\r\n" +
+		"<a@x>\r\n" +
+		"<b@x>\r\n" +
+		".\r\n" +
+		"231 list follows\r\n" +
+		"comp.lang.go 10 1 y\r\n" +
+		"comp.lang.c 10 1 y\r\n" +
+		"alt.test 5 1 n\r\n" +
+		".\r\n"
+	var sent bytes.Buffer
+	c := &Conn{conn: textproto.NewConn(faker{&sent, strings.NewReader(server)})}
+
+	// Noon in UTC+2 is 10:00 GMT.
+	since := time.Date(2010, time.March, 1, 12, 0, 0, 0, time.FixedZone("", 7200))
+	q := NewsQuery{Groups: "comp.*,!comp.lang.c", Since: since, Distributions: []string{"local", "world"}}
+	var ids []string
+	err := c.QueryNewNews(q, func(id string) error {
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil || strings.Join(ids, " ") != "<a@x> .<dot@x> <b@x>" {
+		t.Fatalf("QueryNewNews = %q, %v", ids, err)
+	}
+
+	// An error from fn stops the calls, but the response is consumed.
+	stop := errors.New("stop")
+	ids = nil
+	err = c.QueryNewNews(NewsQuery{Since: since}, func(id string) error {
+		ids = append(ids, id)
+		return stop
+	})
+	if err != stop || len(ids) != 1 {
+		t.Fatalf("QueryNewNews with failing fn = %q, %v", ids, err)
+	}
+
+	groups, err := c.QueryNewGroups(NewsQuery{Groups: "comp.*,!comp.lang.c", Since: since})
+	if err != nil || len(groups) != 1 || groups[0].Name != "comp.lang.go" {
+		t.Fatalf("QueryNewGroups = %v, %v", groups, err)
+	}
+
+	want := "NEWNEWS comp.*,!comp.lang.c 20100301 100000 GMT <local,world>\r\n" +
+		"NEWNEWS * 20100301 100000 GMT\r\n" +
+		"NEWGROUPS 20100301 100000 GMT\r\n"
+	if sent.String() != want {
+		t.Errorf("sent:\n%s\nwant:\n%s", sent.String(), want)
+	}
+
+	for _, q := range []NewsQuery{
+		{Groups: "comp.* alt.*"},
+		{Groups: "comp.[a"},
+		{Distributions: []string{"a,b"}},
+	} {
+		if err := c.QueryNewNews(q, func(string) error { return nil }); err == nil {
+			t.Errorf("QueryNewNews(%+v) should fail", q)
+		}
+	}
+}
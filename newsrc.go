@@ -0,0 +1,310 @@
+package nntp
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Range is an inclusive range of article numbers.
+type Range struct {
+	Low, High int64
+}
+
+// A RangeSet is a sorted list of non-overlapping, non-adjacent ranges of
+// article numbers, as kept in a .newsrc file.
+type RangeSet []Range
+
+// A NewsrcError reports an article range in a .newsrc file that could
+// not be parsed.
+type NewsrcError struct {
+	// Line is the 1-based line number, or 0 if the ranges did not come
+	// from a file.
+	Line  int
+	Range string
+}
+
+func (e *NewsrcError) Error() string {
+	if e.Line == 0 {
+		return "nntp: bad article range " + strconv.Quote(e.Range)
+	}
+	return "nntp: newsrc line " + strconv.Itoa(e.Line) + ": bad article range " + strconv.Quote(e.Range)
+}
+
+// ParseRangeSet parses a comma-separated list such as "1-1234,1240,1250-1300".
+// If a range is malformed the others are still returned, along with a
+// *NewsrcError for the first bad one.
+func ParseRangeSet(s string) (RangeSet, error) {
+	rs, bad := parseRanges(s)
+	if len(bad) > 0 {
+		return rs, &NewsrcError{Range: bad[0]}
+	}
+	return rs, nil
+}
+
+// parseRanges parses what it can of a range list and returns the ranges
+// it could not parse, including reversed ones such as "5-3".
+func parseRanges(s string) (rs RangeSet, bad []string) {
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		lo, hi := f, f
+		if i := strings.IndexByte(f, '-'); i >= 0 {
+			lo, hi = f[:i], f[i+1:]
+		}
+		l, err1 := strconv.ParseInt(lo, 10, 64)
+		h, err2 := strconv.ParseInt(hi, 10, 64)
+		if err1 != nil || err2 != nil || l > h {
+			bad = append(bad, f)
+			continue
+		}
+		rs = rs.Add(l, h)
+	}
+	return rs, bad
+}
+
+// Contains reports whether n is in the set.
+func (rs RangeSet) Contains(n int64) bool {
+	i := sort.Search(len(rs), func(i int) bool { return rs[i].High >= n })
+	return i < len(rs) && rs[i].Low <= n
+}
+
+// Add returns the set with low..high added.
+func (rs RangeSet) Add(low, high int64) RangeSet {
+	if low > high {
+		return rs
+	}
+	out := make(RangeSet, 0, len(rs)+1)
+	i := 0
+	for ; i < len(rs) && rs[i].High < low-1; i++ {
+		out = append(out, rs[i])
+	}
+	for ; i < len(rs) && rs[i].Low <= high+1; i++ {
+		if rs[i].Low < low {
+			low = rs[i].Low
+		}
+		if rs[i].High > high {
+			high = rs[i].High
+		}
+	}
+	out = append(out, Range{low, high})
+	return append(out, rs[i:]...)
+}
+
+// Remove returns the set with low..high removed.
+func (rs RangeSet) Remove(low, high int64) RangeSet {
+	if low > high {
+		return rs
+	}
+	out := make(RangeSet, 0, len(rs)+1)
+	for _, r := range rs {
+		if r.High < low || r.Low > high {
+			out = append(out, r)
+			continue
+		}
+		if r.Low < low {
+			out = append(out, Range{r.Low, low - 1})
+		}
+		if r.High > high {
+			out = append(out, Range{high + 1, r.High})
+		}
+	}
+	return out
+}
+
+// Count returns how many numbers in low..high are in the set.
+func (rs RangeSet) Count(low, high int64) int64 {
+	var n int64
+	for _, r := range rs {
+		l, h := r.Low, r.High
+		if l < low {
+			l = low
+		}
+		if h > high {
+			h = high
+		}
+		if l <= h {
+			n += h - l + 1
+		}
+	}
+	return n
+}
+
+func (rs RangeSet) String() string {
+	var b strings.Builder
+	for i, r := range rs {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatInt(r.Low, 10))
+		if r.High != r.Low {
+			b.WriteByte('-')
+			b.WriteString(strconv.FormatInt(r.High, 10))
+		}
+	}
+	return b.String()
+}
+
+// A NewsrcGroup is one line of a .newsrc file.
+type NewsrcGroup struct {
+	Name       string
+	Subscribed bool
+	Read       RangeSet
+}
+
+// Unread returns the number of articles in g that have not been read.
+// The server's count is only an estimate, so this is too.
+func (n *NewsrcGroup) Unread(g *Group) int64 {
+	if g.High < g.Low {
+		return 0
+	}
+	unread := g.High - g.Low + 1 - n.Read.Count(g.Low, g.High)
+	if g.Count > 0 && unread > g.Count {
+		unread = g.Count
+	}
+	return unread
+}
+
+// A Newsrc is the read state of a user's groups, in the format shared by
+// most Usenet newsreaders:
+//
+//   comp.lang.go: 1-1234,1240
+//   alt.test! 1-10
+//
+// Groups keep their order in the file. Lines that cannot be parsed, such
+// as "options" lines, are preserved when writing it back.
+type Newsrc struct {
+	Groups []*NewsrcGroup
+	// Errors lists the malformed ranges found by ReadNewsrc. They are
+	// left out of the groups' read sets.
+	Errors []*NewsrcError
+	index  map[string]*NewsrcGroup
+	other  map[int][]string // unparsed lines, keyed by the group index they precede
+}
+
+// ReadNewsrc parses a .newsrc file. A malformed range does not stop it:
+// the other ranges of the line are kept and the bad one is listed in
+// Errors. The error returned is only for failures reading r.
+func ReadNewsrc(r io.Reader) (*Newsrc, error) {
+	n := &Newsrc{index: map[string]*NewsrcGroup{}, other: map[int][]string{}}
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<24)
+	for lineno := 1; s.Scan(); lineno++ {
+		line := s.Text()
+		i := strings.IndexAny(line, ":!")
+		if i <= 0 || strings.ContainsAny(line[:i], " \t") {
+			n.other[len(n.Groups)] = append(n.other[len(n.Groups)], line)
+			continue
+		}
+		read, bad := parseRanges(line[i+1:])
+		for _, b := range bad {
+			n.Errors = append(n.Errors, &NewsrcError{Line: lineno, Range: b})
+		}
+		g := n.Group(line[:i])
+		g.Subscribed = line[i] == ':'
+		g.Read = read
+	}
+	return n, s.Err()
+}
+
+// OpenNewsrc reads the .newsrc file at path. A missing file gives an
+// empty Newsrc.
+func OpenNewsrc(path string) (*Newsrc, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ReadNewsrc(strings.NewReader(""))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadNewsrc(f)
+}
+
+// Group returns the entry for a group, adding an unsubscribed one at the
+// end if it is not listed.
+func (n *Newsrc) Group(name string) *NewsrcGroup {
+	if n.index == nil {
+		n.index = map[string]*NewsrcGroup{}
+	}
+	if g, ok := n.index[name]; ok {
+		return g
+	}
+	g := &NewsrcGroup{Name: name}
+	n.Groups = append(n.Groups, g)
+	n.index[name] = g
+	return g
+}
+
+// Subscribe marks a group subscribed, or unsubscribed, adding it if
+// needed.
+func (n *Newsrc) Subscribe(name string, on bool) {
+	n.Group(name).Subscribed = on
+}
+
+// Subscribed returns the subscribed groups in file order.
+func (n *Newsrc) Subscribed() []*NewsrcGroup {
+	var gs []*NewsrcGroup
+	for _, g := range n.Groups {
+		if g.Subscribed {
+			gs = append(gs, g)
+		}
+	}
+	return gs
+}
+
+// MarkRead marks articles low..high in a group as read.
+func (n *Newsrc) MarkRead(group string, low, high int64) {
+	g := n.Group(group)
+	g.Read = g.Read.Add(low, high)
+}
+
+// MarkUnread marks articles low..high in a group as unread.
+func (n *Newsrc) MarkUnread(group string, low, high int64) {
+	g := n.Group(group)
+	g.Read = g.Read.Remove(low, high)
+}
+
+// IsRead reports whether an article has been read.
+func (n *Newsrc) IsRead(group string, number int64) bool {
+	g, ok := n.index[group]
+	return ok && g.Read.Contains(number)
+}
+
+// WriteTo writes the file contents to w.
+func (n *Newsrc) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	for i, g := range n.Groups {
+		for _, line := range n.other[i] {
+			b.WriteString(line + "\n")
+		}
+		b.WriteString(g.Name)
+		if g.Subscribed {
+			b.WriteByte(':')
+		} else {
+			b.WriteByte('!')
+		}
+		if len(g.Read) > 0 {
+			b.WriteString(" " + g.Read.String())
+		}
+		b.WriteByte('\n')
+	}
+	for _, line := range n.other[len(n.Groups)] {
+		b.WriteString(line + "\n")
+	}
+	return b.WriteTo(w)
+}
+
+// Save writes the file to path, replacing it atomically so that other
+// newsreaders never see a partial file.
+func (n *Newsrc) Save(path string) error {
+	var b bytes.Buffer
+	n.WriteTo(&b)
+	return writeFileAtomic(path, b.Bytes())
+}
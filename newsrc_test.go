@@ -0,0 +1,124 @@
+package nntp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRangeSet(t *testing.T) {
+	rs, err := ParseRangeSet("1-10,12,20-30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs = rs.Add(11, 11)
+	if got := rs.String(); got != "1-12,20-30" {
+		t.Errorf("after Add: %s", got)
+	}
+	rs = rs.Remove(5, 6).Remove(30, 40)
+	if got := rs.String(); got != "1-4,7-12,20-29" {
+		t.Errorf("after Remove: %s", got)
+	}
+	if !rs.Contains(7) || rs.Contains(5) || rs.Contains(15) {
+		t.Error("Contains wrong")
+	}
+	if n := rs.Count(10, 25); n != 9 {
+		t.Errorf("Count = %d", n)
+	}
+	if _, err := ParseRangeSet("1-x"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestNewsrc(t *testing.T) {
+	const in = "options -n all\n" +
+		"comp.lang.go: 1-100,105\n" +
+		"alt.test! 1-5\n" +
+		"news.answers:\n"
+	n, err := ReadNewsrc(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subs := n.Subscribed(); len(subs) != 2 || subs[1].Name != "news.answers" {
+		t.Errorf("subscribed: %v", subs)
+	}
+	if !n.IsRead("comp.lang.go", 105) || n.IsRead("comp.lang.go", 104) {
+		t.Error("IsRead wrong")
+	}
+	n.MarkRead("comp.lang.go", 101, 104)
+	n.MarkUnread("alt.test", 3, 3)
+	n.Subscribe("alt.test", true)
+	n.MarkRead("misc.new", 1, 1)
+
+	g := &Group{Name: "comp.lang.go", Low: 50, High: 120, Count: 71}
+	if u := n.Group("comp.lang.go").Unread(g); u != 15 {
+		t.Errorf("unread = %d", u)
+	}
+
+	var b bytes.Buffer
+	n.WriteTo(&b)
+	want := "options -n all\n" +
+		"comp.lang.go: 1-105\n" +
+		"alt.test: 1-2,4-5\n" +
+		"news.answers:\n" +
+		"misc.new! 1\n"
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	dir, err := ioutil.TempDir("", "newsrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, ".newsrc")
+	if err := n.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	n2, err := OpenNewsrc(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	n2.WriteTo(&b)
+	if b.String() != want {
+		t.Errorf("after reload:\n%s", b.String())
+	}
+
+	// Save keeps the mode of the file it replaces.
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := n2.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0644 {
+		t.Errorf("mode after Save: %v, %v", fi.Mode(), err)
+	}
+}
+
+func TestNewsrcBadRange(t *testing.T) {
+	n, err := ReadNewsrc(strings.NewReader("comp.lang.go: 1-10,x-3,20\nalt.test: 5,7-y\nalt.rev: 5-3,8\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := n.Group("comp.lang.go").Read.String(); got != "1-10,20" {
+		t.Errorf("comp.lang.go read %q", got)
+	}
+	if got := n.Group("alt.test").Read.String(); got != "5" {
+		t.Errorf("alt.test read %q", got)
+	}
+	if got := n.Group("alt.rev").Read.String(); got != "8" {
+		t.Errorf("alt.rev read %q", got)
+	}
+	if len(n.Errors) != 3 || n.Errors[0].Line != 1 || n.Errors[0].Range != "x-3" || n.Errors[1].Line != 2 ||
+		n.Errors[2].Line != 3 || n.Errors[2].Range != "5-3" {
+		t.Fatalf("Errors = %v", n.Errors)
+	}
+	if msg := n.Errors[1].Error(); msg != `nntp: newsrc line 2: bad article range "7-y"` {
+		t.Errorf("Error() = %s", msg)
+	}
+}
@@ -4,14 +4,11 @@ package nntp
 
 import (
 	"bufio"
-	"bytes"
 	"compress/zlib"
 	"crypto/tls"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/textproto"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -47,45 +44,48 @@ func (p ProtocolError) Error() string {
 // an io.Reader), that io.Reader is only valid until the next call to a
 // method of Conn.
 type Conn struct {
-	conn     *textproto.Conn
-	Banner   string
-	compress bool
+	conn            *textproto.Conn
+	Banner          string
+	compress        bool
+	logger          Logger
+	trace           bool
+	hooks           Hooks
+	limits          Limits
+	wire            *wireConn
+	lenientOverview bool
+
+	// PostingAllowed reports whether the server said it accepts posts,
+	// in its greeting or in the response to MODE READER.
+	PostingAllowed bool
+	// Server holds hints about the server software from the greeting.
+	Server ServerInfo
 }
 
 // New connects to an NNTP server.
 // The network and addr are passed to net.Dial to
-// make the connection.
+// make the connection. Use Dial for more control over the connection.
 //
 // Example:
-//   conn, err := nntp.Dial("tcp", "my.news:nntp")
+//   conn, err := nntp.New("tcp", "my.news:nntp")
 //
 func New(network, addr string) (*Conn, error) {
-	c, err := textproto.Dial(network, addr)
-	if err != nil {
-		return nil, err
-	}
-	return newClient(c)
+	return Dial(network, addr)
 }
 
 // NewTLS connects with TLS
 func NewTLS(net, addr string, cfg *tls.Config) (*Conn, error) {
-	c, err := tls.Dial(net, addr, cfg)
-	if err != nil {
-		return nil, err
-	}
-	return newClient(textproto.NewConn(c))
+	return Dial(net, addr, WithTLS(cfg))
 }
 
-func newClient(conn *textproto.Conn) (*Conn, error) {
-	_, msg, err := conn.ReadCodeLine(200)
-	if err != nil {
+func newClient(rwc io.ReadWriteCloser) (*Conn, error) {
+	c := &Conn{logger: log.StandardLogger()}
+	c.wire = &wireConn{ReadWriteCloser: rwc, c: c}
+	c.conn = textproto.NewConn(c.wire)
+	if err := c.readGreeting(); err != nil {
+		c.conn.Close()
 		return nil, err
 	}
-
-	return &Conn{
-		conn:   conn,
-		Banner: msg,
-	}, nil
+	return c, nil
 }
 
 // Command sends a low-level command and get a response.
@@ -96,36 +96,25 @@ func newClient(conn *textproto.Conn) (*Conn, error) {
 // 200 (inclusive) to 300 (exclusive) will be success.  An expectCode
 // of -1 disables this behavior.
 func (c *Conn) Command(cmd string, expectCode int) (int, string, error) {
-	log.Infof("client: %s", cmd)
-	err := c.conn.PrintfLine(cmd)
-	if err != nil {
-		return 0, "", err
-	}
-	code, msg, err := c.conn.ReadCodeLine(expectCode)
-	log.Infof("server code: %d, msg: %s, err: %v", code, msg, err)
+	ev := c.begin(cmd)
+	code, msg, err := c.exec(ev, cmd, expectCode)
+	c.end(ev, err)
 	return code, msg, err
 }
 
 // MultilineCommand wraps the functionality to
-func (c *Conn) MultilineCommand(cmd string, expectCode int) (int, []string, error) {
-	log.Infof("client: %s", cmd)
-	err := c.conn.PrintfLine(cmd)
-	if err != nil {
-		return 0, nil, err
-	}
-	rc, l, err := c.conn.ReadCodeLine(expectCode)
-	log.Infof("server code: %d, msg: %s, err: %v", rc, l, err)
+func (c *Conn) MultilineCommand(cmd string, expectCode int) (rc int, lines []string, err error) {
+	ev := c.begin(cmd)
+	defer func() { c.end(ev, err) }()
+	rc, l, err := c.exec(ev, cmd, expectCode)
 	if err != nil {
 		return rc, nil, err
 	}
-	lines := []string{l}
+	lines = []string{l}
 	ls, err := c.conn.ReadDotLines()
 	if err != nil {
 		return rc, nil, err
 	}
-	for _, line := range ls {
-		log.Debugf("server: %v", line)
-	}
 	lines = append(lines, ls...)
 	return rc, lines, err
 }
@@ -144,15 +133,35 @@ type Group struct {
 }
 
 // An Article represents an NNTP article.
+//
+// Articles read from the server or with ReadArticle carry their header
+// twice: in Header, keyed by canonical name, and in Fields, in original
+// order and form. When Fields is set it wins: String, WriteTo and the
+// header lookups use it, and changes made to Header alone are ignored.
+// Use SetHeader, AddHeader and DelHeader to change both, or set Fields
+// to nil to work from Header only.
 type Article struct {
 	Header map[string][]string
+	// Fields holds the same header in its original order and form. It is
+	// set for articles read from the server.
+	Fields OrderedHeader
 	Body   []string
 }
 
 func (a *Article) String() string {
 	res := []string{}
-	for k, v := range a.Header {
-		res = append(res, fmt.Sprintf("%s: %s", k, strings.Join(v, ",")))
+	if a.Fields != nil {
+		for _, f := range a.Fields {
+			if f.Name == "" {
+				res = append(res, strings.TrimRight(string(f.Raw), "\r\n"))
+				continue
+			}
+			res = append(res, fmt.Sprintf("%s: %s", f.Name, f.Value))
+		}
+	} else {
+		for k, v := range a.Header {
+			res = append(res, fmt.Sprintf("%s: %s", k, strings.Join(v, ",")))
+		}
 	}
 	res = append(res, "")
 	res = append(res, a.Body...)
@@ -188,44 +197,40 @@ func (c *Conn) SetCompression() error {
 }
 
 // ModeReader switches the NNTP server to "reader" mode, if it
-// is a mode-switching server.
+// is a mode-switching server. PostingAllowed is updated from the
+// response, since a server may only allow posting in reader mode.
 func (c *Conn) ModeReader() error {
-	_, _, err := c.Command("MODE READER", 20)
-	return err
+	code, _, err := c.Command("MODE READER", 20)
+	if err != nil {
+		return err
+	}
+	c.PostingAllowed = code == 200
+	return nil
 }
 
 // NewGroups returns a list of groups added since the given time.
 func (c *Conn) NewGroups(since time.Time) ([]*Group, error) {
-	_, _, err := c.Command(fmt.Sprintf("NEWGROUPS %s GMT", since.Format(timeFormatNew)), 231)
-	if err != nil {
-		return nil, err
-	}
-	lines, err := c.conn.ReadDotLines()
-	if err != nil {
-		return nil, err
-	}
-	return parseNewGroups(lines)
+	return c.QueryNewGroups(NewsQuery{Since: since})
 }
 
 // NewNews returns a list of the IDs of articles posted
-// to the given group since the given time.
+// to the given group, which may be a wildmat, since the given time.
+// IDs are in the order the server sent them, without duplicates.
+// Use QueryNewNews to process them as they arrive instead.
 func (c *Conn) NewNews(group string, since time.Time) ([]string, error) {
-	_, lines, err := c.MultilineCommand(fmt.Sprintf("NEWNEWS %s %s GMT", group, since.Format(timeFormatNew)), 230)
+	ids := []string{}
+	seen := map[string]bool{}
+	err := c.QueryNewNews(NewsQuery{Groups: group, Since: since}, func(id string) error {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	sort.Strings(lines)
-	w := 0
-	for r, s := range lines {
-		if r == 0 || lines[r-1] != s {
-			lines[w] = s
-			w++
-		}
-	}
-	lines = lines[0:w]
-
-	return lines, nil
+	return ids, nil
 }
 
 // MessageOverview of a message returned by OVER/XOVER command.
@@ -253,25 +258,57 @@ func (m *MessageOverview) Xref() string {
 }
 
 // Overview returns overviews of all messages in the current group with message number between
-// begin and end, inclusive.
-func (c *Conn) Overview(begin, end int64) ([]MessageOverview, error) {
-	_, _, err := c.Command(fmt.Sprintf("XOVER %d-%d", begin, end), 224)
+// begin and end, inclusive. A malformed line fails the whole call with a
+// ProtocolError unless SetLenientOverview is on.
+func (c *Conn) Overview(begin, end int64) (overviews []MessageOverview, err error) {
+	lines, err := c.overviewLines(begin, end)
 	if err != nil {
 		return nil, err
 	}
-
+	if c.lenientOverview {
+		result, report := ParseOverview(lines)
+		for _, e := range report.Errors {
+			c.debugf("%v", e)
+		}
+		return result, nil
+	}
 	result := []MessageOverview{}
-	var lines []string
+	for _, line := range lines {
+		if "" == line {
+			return result, nil
+		}
+		overview, err := parseOverviewLine(line)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, overview)
+	}
+	return result, nil
+}
+
+// overviewLines sends XOVER for the range and returns the response lines,
+// decompressing them if needed.
+func (c *Conn) overviewLines(begin, end int64) (lines []string, err error) {
+	cmd := fmt.Sprintf("XOVER %d-%d", begin, end)
+	ev := c.begin(cmd)
+	defer func() { c.end(ev, err) }()
+	_, _, err = c.exec(ev, cmd, 224)
+	if err != nil {
+		return nil, err
+	}
+
 	if c.compress {
-		log.Debugf("Reading compressed data")
+		c.debugf("Reading compressed data")
 		zr, err := zlib.NewReader(c.conn.R)
 		if err != nil {
 			return nil, err
 		}
 		defer zr.Close()
+		ev.Compressed = true
 		scanner := bufio.NewScanner(zr)
 		for scanner.Scan() {
 			l := scanner.Text()
+			ev.DecodedBytes += int64(len(l)) + 2
 			if "." == l {
 				break
 			}
@@ -279,47 +316,47 @@ func (c *Conn) Overview(begin, end int64) ([]MessageOverview, error) {
 		}
 		//Read last dot out of buffer
 		c.conn.ReadLine()
-	} else {
-		lines, err = c.conn.ReadDotLines()
-		log.Debugf("Read %d lines from connection", len(lines))
-		if err != nil {
-			return nil, err
-		}
+		return lines, nil
 	}
-	for _, line := range lines {
-		if "" == line {
-			return result, nil
-		}
-		overview := MessageOverview{}
-		ss := strings.SplitN(strings.TrimSpace(line), "\t", 9)
-		if len(ss) < 8 {
-			return nil, ProtocolError("short header listing line: " + line + strconv.Itoa(len(ss)))
-		}
-		overview.MessageNumber, err = strconv.ParseInt(ss[0], 10, 64)
-		if err != nil {
-			return nil, ProtocolError("bad message number '" + ss[0] + "' in line: " + line)
-		}
-		overview.Subject = ss[1]
-		overview.From = ss[2]
-		overview.Date, err = parseDate(ss[3])
-		if err != nil {
-			// Inability to parse date is not fatal: the field in the message may be broken or missing.
-			overview.Date = time.Time{}
-		}
-		overview.MessageID = ss[4]
-		overview.References = strings.Split(ss[5], " ") // Message-Id's contain no spaces, so this is safe.
-		overview.Bytes, err = strconv.Atoi(ss[6])
-		if err != nil {
-			return nil, ProtocolError("bad byte count '" + ss[6] + "'in line:" + line)
-		}
-		overview.Lines, err = strconv.Atoi(ss[7])
-		if err != nil {
-			return nil, ProtocolError("bad line count '" + ss[7] + "'in line:" + line)
-		}
-		overview.Extra = append([]string{}, ss[8:]...)
-		result = append(result, overview)
+	lines, err = c.conn.ReadDotLines()
+	c.debugf("Read %d lines from connection", len(lines))
+	if err != nil {
+		return nil, err
 	}
-	return result, nil
+	return lines, nil
+}
+
+// parseOverviewLine parses one line of an XOVER response.
+func parseOverviewLine(line string) (MessageOverview, error) {
+	var err error
+	overview := MessageOverview{}
+	ss := strings.SplitN(strings.TrimSpace(line), "\t", 9)
+	if len(ss) < 8 {
+		return overview, ProtocolError("short header listing line: " + line + strconv.Itoa(len(ss)))
+	}
+	overview.MessageNumber, err = strconv.ParseInt(ss[0], 10, 64)
+	if err != nil {
+		return overview, ProtocolError("bad message number '" + ss[0] + "' in line: " + line)
+	}
+	overview.Subject = ss[1]
+	overview.From = ss[2]
+	overview.Date, err = ParseDate(ss[3])
+	if err != nil {
+		// Inability to parse date is not fatal: the field in the message may be broken or missing.
+		overview.Date = time.Time{}
+	}
+	overview.MessageID = ss[4]
+	overview.References = strings.Split(ss[5], " ") // Message-Id's contain no spaces, so this is safe.
+	overview.Bytes, err = strconv.Atoi(ss[6])
+	if err != nil {
+		return overview, ProtocolError("bad byte count '" + ss[6] + "'in line:" + line)
+	}
+	overview.Lines, err = strconv.Atoi(ss[7])
+	if err != nil {
+		return overview, ProtocolError("bad line count '" + ss[7] + "'in line:" + line)
+	}
+	overview.Extra = append([]string{}, ss[8:]...)
+	return overview, nil
 }
 
 func parseGroup(line string) (*Group, error) {
@@ -442,33 +479,41 @@ func (c *Conn) Help() ([]string, error) {
 }
 
 // nextLastStat performs the work for NEXT, LAST, and STAT.
-func (c *Conn) nextLastStat(cmd, id string) (string, string, error) {
+func (c *Conn) nextLastStat(cmd, id string) (ArticlePointer, error) {
 	_, line, err := c.Command(maybeID(cmd, id), 223)
 	if err != nil {
-		return "", "", err
+		return ArticlePointer{}, articleErr(err)
 	}
 	ss := strings.SplitN(line, " ", 3) // optional comment ignored
 	if len(ss) < 2 {
-		return "", "", ProtocolError("Bad response to " + cmd + ": " + line)
+		return ArticlePointer{}, ProtocolError("Bad response to " + cmd + ": " + line)
 	}
-	return ss[0], ss[1], nil
+	n, err := strconv.ParseInt(ss[0], 10, 64)
+	if err != nil {
+		return ArticlePointer{}, ProtocolError("bad article number in response to " + cmd + ": " + line)
+	}
+	return ArticlePointer{Number: n, MessageID: ss[1]}, nil
 }
 
-// Stat looks up the message with the given id and returns its
-// message number in the current group, and vice versa.
-// The returned message number can be "0" if the current group
+// Stat looks up the message with the given id, which may be a
+// message-id or a number in the current group, and selects it if it
+// was given by number. An empty id returns the current article.
+// The returned message number is 0 if the current group
 // isn't one of the groups the message was posted to.
-func (c *Conn) Stat(id string) (number, msgid string, err error) {
+// A missing article gives an error matching ErrNoSuchArticle.
+func (c *Conn) Stat(id string) (ArticlePointer, error) {
 	return c.nextLastStat("STAT", id)
 }
 
-// Last selects the previous article, returning its message number and id.
-func (c *Conn) Last() (number, msgid string, err error) {
+// Last selects the previous article. At the first article it returns
+// an error matching ErrNoPreviousArticle.
+func (c *Conn) Last() (ArticlePointer, error) {
 	return c.nextLastStat("LAST", "")
 }
 
-// Next selects the next article, returning its message number and id.
-func (c *Conn) Next() (number, msgid string, err error) {
+// Next selects the next article. At the last article it returns an error
+// matching ErrNoNextArticle.
+func (c *Conn) Next() (ArticlePointer, error) {
 	return c.nextLastStat("NEXT", "")
 }
 
@@ -483,17 +528,25 @@ func (c *Conn) ArticleText(id string) ([]string, error) {
 }
 
 // Article returns the article named by id as an *Article.
-func (c *Conn) Article(id string) (*Article, error) {
-	_, _, err := c.Command(maybeID("ARTICLE", id), 220)
+func (c *Conn) Article(id string) (a *Article, err error) {
+	cmd := maybeID("ARTICLE", id)
+	ev := c.begin(cmd)
+	defer func() { c.end(ev, err) }()
+	_, _, err = c.exec(ev, cmd, 220)
 	if err != nil {
 		return nil, err
 	}
-	h, err := c.conn.ReadMIMEHeader()
+	h, eof, err := readWireHeader(&c.conn.Reader)
 	if err != nil {
 		return nil, err
 	}
-	a := &Article{}
-	a.Header = h
+	a = &Article{
+		Header: h.MIMEHeader(),
+		Fields: h,
+	}
+	if eof {
+		return a, nil
+	}
 	a.Body, err = c.conn.ReadDotLines()
 	if err != nil {
 		return nil, err
@@ -514,35 +567,41 @@ func (c *Conn) HeadText(id string) ([]string, error) {
 
 // Head returns the header for the article named by id as an *Article.
 // The Body field in the Article is nil.
-func (c *Conn) Head(id string) (*Article, error) {
-	_, _, err := c.Command(maybeID("HEAD", id), 221)
+func (c *Conn) Head(id string) (a *Article, err error) {
+	cmd := maybeID("HEAD", id)
+	ev := c.begin(cmd)
+	defer func() { c.end(ev, err) }()
+	_, _, err = c.exec(ev, cmd, 221)
 	if err != nil {
 		return nil, err
 	}
-	r := c.conn.DotReader()
-	header, err := ioutil.ReadAll(r)
+	h, eof, err := readWireHeader(&c.conn.Reader)
 	if err != nil {
 		return nil, err
 	}
-	header = append(header, []byte("\n")...)
-	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(header)))
-	headerStruct, err := tp.ReadMIMEHeader()
-	if err != nil {
-		return nil, err
+	if !eof {
+		// Discard anything after a blank line in the header block.
+		if _, err = c.conn.ReadDotLines(); err != nil {
+			return nil, err
+		}
 	}
-	a := &Article{
-		Header: headerStruct,
+	a = &Article{
+		Header: h.MIMEHeader(),
+		Fields: h,
 	}
 	return a, nil
 }
 
 // Body returns the body for the article named by id as an io.Reader.
-func (c *Conn) Body(id string) ([]string, error) {
-	_, _, err := c.Command(maybeID("BODY", id), 222)
+func (c *Conn) Body(id string) (lines []string, err error) {
+	cmd := maybeID("BODY", id)
+	ev := c.begin(cmd)
+	defer func() { c.end(ev, err) }()
+	_, _, err = c.exec(ev, cmd, 222)
 	if err != nil {
 		return nil, err
 	}
-	lines, err := c.conn.ReadDotLines()
+	lines, err = c.conn.ReadDotLines()
 	if err != nil {
 		return nil, err
 	}
@@ -550,44 +609,66 @@ func (c *Conn) Body(id string) ([]string, error) {
 }
 
 // RawPost reads a text-formatted article from r and posts it to the server.
-func (c *Conn) RawPost(r io.Reader) error {
-	_, _, err := c.Command("POST", 3)
+func (c *Conn) RawPost(r io.Reader) (err error) {
+	ev := c.begin("POST")
+	defer func() { c.end(ev, err) }()
+	_, _, err = c.exec(ev, "POST", 3)
 	if err != nil {
 		return err
 	}
+	if err = c.writeText(r); err != nil {
+		return err
+	}
+
+	_, _, err = c.exec(ev, ".", 240)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// IHave offers an article to the server for transfer, as a peer would,
+// and sends it if the server wants it. The article is read from r as in
+// RawPost. A 435 response means the server already has the article and
+// a 437 that it was rejected; both are returned as *textproto.Error.
+func (c *Conn) IHave(msgid string, r io.Reader) (err error) {
+	cmd := "IHAVE " + msgid
+	ev := c.begin(cmd)
+	defer func() { c.end(ev, err) }()
+	if _, _, err = c.exec(ev, cmd, 335); err != nil {
+		return err
+	}
+	if err = c.writeText(r); err != nil {
+		return err
+	}
+	_, _, err = c.exec(ev, ".", 235)
+	return err
+}
+
+// writeText copies a text article from r to the server, dot-stuffing
+// lines and ending them in CRLF whether they ended in LF or CRLF.
+func (c *Conn) writeText(r io.Reader) error {
 	br := bufio.NewReader(r)
-	eof := false
 	for {
 		line, err := br.ReadString('\n')
-		if err == io.EOF {
-			eof = true
-		} else if err != nil {
+		if err != nil && err != io.EOF {
 			return err
 		}
-		if eof && len(line) == 0 {
-			break
-		}
-		if strings.HasSuffix(line, "\n") {
-			line = line[0 : len(line)-1]
+		if err == io.EOF && len(line) == 0 {
+			return nil
 		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
 		var prefix string
 		if strings.HasPrefix(line, ".") {
 			prefix = "."
 		}
-		_, err = fmt.Fprintf(c.conn.W, "%s%s\r\n", prefix, line)
-		if err != nil {
-			return err
+		if _, werr := fmt.Fprintf(c.conn.W, "%s%s\r\n", prefix, line); werr != nil {
+			return werr
 		}
-		if eof {
-			break
+		if err == io.EOF {
+			return nil
 		}
 	}
-
-	_, _, err = c.Command(".", 240)
-	if err != nil {
-		return err
-	}
-	return nil
 }
 
 // Quit sends the QUIT command and closes the connection to the server.
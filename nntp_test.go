@@ -92,13 +92,13 @@ func TestBasics(t *testing.T) {
 	}
 
 	// test STAT, NEXT, and LAST
-	if _, _, err = conn.Stat(""); err != nil {
+	if _, err = conn.Stat(""); err != nil {
 		t.Fatal("should be able to STAT after selecting a group: " + err.Error())
 	}
-	if _, _, err = conn.Next(); err != nil {
+	if _, err = conn.Next(); err != nil {
 		t.Fatal("should be able to NEXT after selecting a group: " + err.Error())
 	}
-	if _, _, err = conn.Last(); err != nil {
+	if _, err = conn.Last(); err != nil {
 		t.Fatal("should be able to LAST after a NEXT selecting a group: " + err.Error())
 	}
 
@@ -117,8 +117,8 @@ Fin.`
 		t.Fatalf("article body read incorrectly; got:\n%s\nExpected:\n%s", body, expectedbody)
 	}
 
-	// Test articleReader
-	expectedart := `Message-Id: <b@c.d>
+	// Test articleReader; header names keep their original case.
+	expectedart := `Message-ID: <b@c.d>
 
 Body.`
 	a, err = conn.Article(fmt.Sprintf("%d", grp.Low+1))
@@ -0,0 +1,218 @@
+// Package nntpbolt stores NNTP overviews in a bbolt database, a pure-Go
+// embedded key/value file.
+//
+//   db, err := nntpbolt.Open("overviews.db")
+//   syncer := nntp.NewSyncer(conn, "news.example.com", marks, nil)
+//   syncer.Store = db
+//
+// Each server has a bucket holding a bucket of overviews per group, keyed
+// by article number, and an index from message-id to group and number.
+package nntpbolt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/zeddD1abl0/nntp"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	groupsBucket = []byte("groups")
+	msgidBucket  = []byte("msgids")
+)
+
+// A DB is an nntp.OverviewStore backed by a bbolt file. It is safe for
+// concurrent use; bbolt allows only one process to open the file.
+type DB struct {
+	db *bolt.DB
+}
+
+// Open opens or creates the database at path.
+func Open(path string) (*DB, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{db: db}, nil
+}
+
+// Close closes the database file.
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+func key(n int64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(n))
+	return b[:]
+}
+
+// indexValue is the message-id index entry: the group name followed by
+// the article number.
+func indexValue(group string, n int64) []byte {
+	return append([]byte(group), key(n)...)
+}
+
+func splitIndexValue(v []byte) (string, []byte) {
+	if len(v) < 8 {
+		return "", nil
+	}
+	return string(v[:len(v)-8]), v[len(v)-8:]
+}
+
+// PutOverviews implements nntp.OverviewStore.
+func (d *DB) PutOverviews(server, group string, overviews []nntp.MessageOverview) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		sb, err := tx.CreateBucketIfNotExists([]byte(server))
+		if err != nil {
+			return err
+		}
+		groups, err := sb.CreateBucketIfNotExists(groupsBucket)
+		if err != nil {
+			return err
+		}
+		gb, err := groups.CreateBucketIfNotExists([]byte(group))
+		if err != nil {
+			return err
+		}
+		ids, err := sb.CreateBucketIfNotExists(msgidBucket)
+		if err != nil {
+			return err
+		}
+		for _, ov := range overviews {
+			data, err := json.Marshal(ov)
+			if err != nil {
+				return err
+			}
+			k := key(ov.MessageNumber)
+			if err = dropIndex(ids, gb.Get(k), indexValue(group, ov.MessageNumber)); err != nil {
+				return err
+			}
+			if err = gb.Put(k, data); err != nil {
+				return err
+			}
+			if ov.MessageID != "" {
+				if err = ids.Put([]byte(ov.MessageID), indexValue(group, ov.MessageNumber)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// dropIndex removes the message-id index entry of the overview stored
+// as old, if the entry still points at its slot, before the slot is
+// overwritten. A crossposted message-id indexed under another group is
+// left alone.
+func dropIndex(ids *bolt.Bucket, old, slot []byte) error {
+	if old == nil {
+		return nil
+	}
+	var ov nntp.MessageOverview
+	if json.Unmarshal(old, &ov) != nil || ov.MessageID == "" {
+		return nil
+	}
+	id := []byte(ov.MessageID)
+	if !bytes.Equal(ids.Get(id), slot) {
+		return nil
+	}
+	return ids.Delete(id)
+}
+
+func groupBucket(tx *bolt.Tx, server, group string) *bolt.Bucket {
+	sb := tx.Bucket([]byte(server))
+	if sb == nil {
+		return nil
+	}
+	groups := sb.Bucket(groupsBucket)
+	if groups == nil {
+		return nil
+	}
+	return groups.Bucket([]byte(group))
+}
+
+// ScanOverviews implements nntp.OverviewStore.
+func (d *DB) ScanOverviews(server, group string, begin, end int64, fn func(nntp.MessageOverview) error) error {
+	return d.db.View(func(tx *bolt.Tx) error {
+		gb := groupBucket(tx, server, group)
+		if gb == nil {
+			return nil
+		}
+		c := gb.Cursor()
+		for k, v := c.Seek(key(begin)); k != nil && int64(binary.BigEndian.Uint64(k)) <= end; k, v = c.Next() {
+			var ov nntp.MessageOverview
+			if err := json.Unmarshal(v, &ov); err != nil {
+				return err
+			}
+			if err := fn(ov); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// OverviewByMessageID implements nntp.OverviewStore.
+func (d *DB) OverviewByMessageID(server, msgid string) (group string, ov nntp.MessageOverview, err error) {
+	err = d.db.View(func(tx *bolt.Tx) error {
+		sb := tx.Bucket([]byte(server))
+		if sb == nil {
+			return nntp.ErrOverviewNotFound
+		}
+		var v []byte
+		if ids := sb.Bucket(msgidBucket); ids != nil {
+			v = ids.Get([]byte(msgid))
+		}
+		var k []byte
+		group, k = splitIndexValue(v)
+		gb := groupBucket(tx, server, group)
+		if k == nil || gb == nil {
+			return nntp.ErrOverviewNotFound
+		}
+		data := gb.Get(k)
+		if data == nil {
+			return nntp.ErrOverviewNotFound
+		}
+		return json.Unmarshal(data, &ov)
+	})
+	return group, ov, err
+}
+
+// Expire implements nntp.OverviewStore.
+func (d *DB) Expire(server, group string, low int64) (int, error) {
+	n := 0
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		gb := groupBucket(tx, server, group)
+		if gb == nil {
+			return nil
+		}
+		ids := tx.Bucket([]byte(server)).Bucket(msgidBucket)
+		// Collect first: deleting while iterating can skip entries.
+		var keys [][]byte
+		c := gb.Cursor()
+		for k, v := c.First(); k != nil && int64(binary.BigEndian.Uint64(k)) < low; k, v = c.Next() {
+			keys = append(keys, k)
+			var ov nntp.MessageOverview
+			if json.Unmarshal(v, &ov) != nil || ids == nil {
+				continue
+			}
+			// Only drop the index entry if it still points here.
+			if g, ik := splitIndexValue(ids.Get([]byte(ov.MessageID))); g == group && string(ik) == string(k) {
+				if err := ids.Delete([]byte(ov.MessageID)); err != nil {
+					return err
+				}
+			}
+		}
+		for _, k := range keys {
+			if err := gb.Delete(k); err != nil {
+				return err
+			}
+		}
+		n = len(keys)
+		return nil
+	})
+	return n, err
+}
@@ -0,0 +1,130 @@
+package nntpbolt
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zeddD1abl0/nntp"
+)
+
+type source struct {
+	low, high int64
+}
+
+func (s *source) Group(name string) (*nntp.Group, error) {
+	return &nntp.Group{Name: name, Low: s.low, High: s.high}, nil
+}
+
+func (s *source) Overview(begin, end int64) ([]nntp.MessageOverview, error) {
+	var res []nntp.MessageOverview
+	for n := begin; n <= end; n++ {
+		res = append(res, nntp.MessageOverview{
+			MessageNumber: n,
+			Subject:       fmt.Sprintf("article %d", n),
+			MessageID:     fmt.Sprintf("<%d@example.com>", n),
+		})
+	}
+	return res, nil
+}
+
+func TestSyncIntoDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nntpbolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := Open(filepath.Join(dir, "ov.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	marks, err := nntp.OpenFileMarkStore(filepath.Join(dir, "marks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := &source{low: 1, high: 50}
+	s := nntp.NewSyncer(src, "news", marks, nil)
+	s.Store = db
+	s.ChunkSize = 20
+	if n, err := s.Sync("alt.test"); err != nil || n != 50 {
+		t.Fatalf("sync: %d, %v", n, err)
+	}
+
+	var got []int64
+	err = db.ScanOverviews("news", "alt.test", 10, 14, func(ov nntp.MessageOverview) error {
+		got = append(got, ov.MessageNumber)
+		return nil
+	})
+	if err != nil || fmt.Sprint(got) != "[10 11 12 13 14]" {
+		t.Errorf("scan: %v, %v", got, err)
+	}
+
+	group, ov, err := db.OverviewByMessageID("news", "<42@example.com>")
+	if err != nil || group != "alt.test" || ov.Subject != "article 42" {
+		t.Errorf("lookup: %q %+v %v", group, ov, err)
+	}
+	if _, _, err := db.OverviewByMessageID("other", "<42@example.com>"); err != nntp.ErrOverviewNotFound {
+		t.Errorf("lookup on other server: %v", err)
+	}
+
+	// Articles below the new low water mark are expired on the next sync.
+	src.low, src.high = 31, 55
+	if n, err := s.Sync("alt.test"); err != nil || n != 5 {
+		t.Fatalf("second sync: %d, %v", n, err)
+	}
+	count := 0
+	db.ScanOverviews("news", "alt.test", 0, 100, func(nntp.MessageOverview) error {
+		count++
+		return nil
+	})
+	if count != 25 {
+		t.Errorf("%d overviews stored after expiry, want 25", count)
+	}
+	if _, _, err := db.OverviewByMessageID("news", "<5@example.com>"); err != nntp.ErrOverviewNotFound {
+		t.Errorf("expired article still indexed: %v", err)
+	}
+}
+
+func TestPutOverwrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nntpbolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := Open(filepath.Join(dir, "ov.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	put := func(group, msgid string) {
+		ov := nntp.MessageOverview{MessageNumber: 5, MessageID: msgid, Subject: msgid}
+		if err := db.PutOverviews("news", group, []nntp.MessageOverview{ov}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("alt.test", "<a@x>")
+	put("alt.other", "<c@x>")
+	// A crosspost of <c@x> indexed under alt.test is not dropped when the
+	// alt.other slot is overwritten.
+	put("alt.test", "<b@x>")
+	put("alt.test", "<c@x>")
+	put("alt.other", "<d@x>")
+
+	if _, _, err := db.OverviewByMessageID("news", "<a@x>"); err != nntp.ErrOverviewNotFound {
+		t.Errorf("overwritten <a@x> still indexed: %v", err)
+	}
+	if _, _, err := db.OverviewByMessageID("news", "<b@x>"); err != nntp.ErrOverviewNotFound {
+		t.Errorf("overwritten <b@x> still indexed: %v", err)
+	}
+	if group, ov, err := db.OverviewByMessageID("news", "<c@x>"); err != nil || group != "alt.test" || ov.MessageNumber != 5 {
+		t.Errorf("lookup <c@x>: %q %+v %v", group, ov, err)
+	}
+	if group, _, err := db.OverviewByMessageID("news", "<d@x>"); err != nil || group != "alt.other" {
+		t.Errorf("lookup <d@x>: %q %v", group, err)
+	}
+}
@@ -0,0 +1,66 @@
+// Package nntpotel creates OpenTelemetry spans for NNTP commands.
+//
+//   conn.SetHooks(nntpotel.NewHooks(otel.Tracer("nntp")))
+package nntpotel
+
+import (
+	"context"
+
+	"github.com/zeddD1abl0/nntp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Hooks starts a client span for every command on a connection.
+type Hooks struct {
+	tracer trace.Tracer
+	// Context returns the parent context for new spans. It defaults to
+	// context.Background; set it to tie commands to the caller's trace.
+	Context func() context.Context
+	// Attributes are added to every span, e.g. the server address.
+	Attributes []attribute.KeyValue
+}
+
+// NewHooks returns Hooks that record spans with tracer.
+func NewHooks(tracer trace.Tracer) *Hooks {
+	return &Hooks{tracer: tracer}
+}
+
+// BeforeCommand implements nntp.Hooks.
+func (h *Hooks) BeforeCommand(ev *nntp.CommandEvent) {
+	ctx := context.Background()
+	if h.Context != nil {
+		ctx = h.Context()
+	}
+	attrs := append([]attribute.KeyValue{
+		attribute.String("nntp.command", ev.Command),
+		attribute.String("nntp.command_line", ev.Line),
+	}, h.Attributes...)
+	_, span := h.tracer.Start(ctx, "NNTP "+ev.Command,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(ev.Start),
+		trace.WithAttributes(attrs...))
+	ev.Value = span
+}
+
+// AfterCommand implements nntp.Hooks.
+func (h *Hooks) AfterCommand(ev *nntp.CommandEvent) {
+	span, ok := ev.Value.(trace.Span)
+	if !ok {
+		return
+	}
+	span.SetAttributes(
+		attribute.Int("nntp.response_code", ev.Code),
+		attribute.Int64("nntp.bytes_sent", ev.BytesSent),
+		attribute.Int64("nntp.bytes_received", ev.BytesReceived),
+	)
+	if ev.Compressed {
+		span.SetAttributes(attribute.Int64("nntp.decompressed_bytes", ev.DecodedBytes))
+	}
+	if ev.Err != nil {
+		span.RecordError(ev.Err)
+		span.SetStatus(codes.Error, ev.Err.Error())
+	}
+	span.End(trace.WithTimestamp(ev.Start.Add(ev.Duration)))
+}
@@ -0,0 +1,47 @@
+package nntpotel
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zeddD1abl0/nntp"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestHooks(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	h := NewHooks(tp.Tracer("test"))
+
+	start := time.Now()
+	ev := &nntp.CommandEvent{Command: "GROUP", Line: "GROUP alt.test", Start: start}
+	h.BeforeCommand(ev)
+	ev.Duration = 5 * time.Millisecond
+	ev.Code = 411
+	ev.Err = errors.New("411 no such group")
+	h.AfterCommand(ev)
+
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans", len(spans))
+	}
+	s := spans[0]
+	if s.Name() != "NNTP GROUP" || s.Status().Code != codes.Error {
+		t.Errorf("unexpected span %q status %v", s.Name(), s.Status())
+	}
+	if d := s.EndTime().Sub(s.StartTime()); d != 5*time.Millisecond {
+		t.Errorf("span duration = %v", d)
+	}
+	found := false
+	for _, kv := range s.Attributes() {
+		if kv.Key == "nntp.response_code" && kv.Value.AsInt64() == 411 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("response code attribute missing: %v", s.Attributes())
+	}
+}
@@ -0,0 +1,84 @@
+// Package nntpprom exports NNTP command metrics to Prometheus.
+//
+//   c := nntpprom.NewCollector(prometheus.Labels{"server": "news.example.com"})
+//   prometheus.MustRegister(c)
+//   conn.SetHooks(c)
+//
+// One Collector can observe any number of connections.
+package nntpprom
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zeddD1abl0/nntp"
+)
+
+// A Collector records command metrics from nntp.Conn hooks and exposes
+// them as a prometheus.Collector.
+type Collector struct {
+	duration   *prometheus.HistogramVec
+	responses  *prometheus.CounterVec
+	sent       *prometheus.CounterVec
+	received   *prometheus.CounterVec
+	compressed *prometheus.CounterVec
+	decoded    *prometheus.CounterVec
+}
+
+// NewCollector returns a Collector whose metrics carry the given constant
+// labels, typically identifying the server or account.
+func NewCollector(labels prometheus.Labels) *Collector {
+	opts := func(name, help string) prometheus.Opts {
+		return prometheus.Opts{Namespace: "nntp", Name: name, Help: help, ConstLabels: labels}
+	}
+	counter := func(name, help string, labelNames ...string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts(opts(name, help)), labelNames)
+	}
+	return &Collector{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   "nntp",
+			Name:        "command_duration_seconds",
+			Help:        "Time from sending a command to reading its full response.",
+			ConstLabels: labels,
+			Buckets:     prometheus.ExponentialBuckets(0.005, 2, 14),
+		}, []string{"command"}),
+		responses:  counter("responses_total", "Responses by command and status code; code 0 means no response.", "command", "code"),
+		sent:       counter("sent_bytes_total", "Bytes written for commands.", "command"),
+		received:   counter("received_bytes_total", "Bytes read for responses, as sent on the wire.", "command"),
+		compressed: counter("compressed_bytes_total", "Wire bytes of compressed responses.", "command"),
+		decoded:    counter("decompressed_bytes_total", "Bytes of compressed responses after decompression.", "command"),
+	}
+}
+
+// BeforeCommand implements nntp.Hooks.
+func (c *Collector) BeforeCommand(ev *nntp.CommandEvent) {}
+
+// AfterCommand implements nntp.Hooks.
+func (c *Collector) AfterCommand(ev *nntp.CommandEvent) {
+	c.duration.WithLabelValues(ev.Command).Observe(ev.Duration.Seconds())
+	c.responses.WithLabelValues(ev.Command, strconv.Itoa(ev.Code)).Inc()
+	c.sent.WithLabelValues(ev.Command).Add(float64(ev.BytesSent))
+	c.received.WithLabelValues(ev.Command).Add(float64(ev.BytesReceived))
+	if ev.Compressed {
+		c.compressed.WithLabelValues(ev.Command).Add(float64(ev.BytesReceived))
+		c.decoded.WithLabelValues(ev.Command).Add(float64(ev.DecodedBytes))
+	}
+}
+
+func (c *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{c.duration, c.responses, c.sent, c.received, c.compressed, c.decoded}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c.collectors() {
+		m.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c.collectors() {
+		m.Collect(ch)
+	}
+}
@@ -0,0 +1,41 @@
+package nntpprom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/zeddD1abl0/nntp"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector(prometheus.Labels{"server": "test"})
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+
+	for _, ev := range []*nntp.CommandEvent{
+		{Command: "XOVER", Code: 224, Duration: time.Millisecond, BytesSent: 10, BytesReceived: 100, Compressed: true, DecodedBytes: 400},
+		{Command: "XOVER", Code: 224, Duration: time.Millisecond, BytesSent: 10, BytesReceived: 50, Compressed: true, DecodedBytes: 200},
+		{Command: "ARTICLE", Code: 430, Duration: time.Millisecond, BytesSent: 20, BytesReceived: 30},
+	} {
+		c.BeforeCommand(ev)
+		c.AfterCommand(ev)
+	}
+
+	if got := testutil.ToFloat64(c.responses.WithLabelValues("XOVER", "224")); got != 2 {
+		t.Errorf("XOVER 224 responses = %v", got)
+	}
+	if got := testutil.ToFloat64(c.responses.WithLabelValues("ARTICLE", "430")); got != 1 {
+		t.Errorf("ARTICLE 430 responses = %v", got)
+	}
+	if got := testutil.ToFloat64(c.compressed.WithLabelValues("XOVER")); got != 150 {
+		t.Errorf("compressed bytes = %v", got)
+	}
+	if got := testutil.ToFloat64(c.decoded.WithLabelValues("XOVER")); got != 600 {
+		t.Errorf("decompressed bytes = %v", got)
+	}
+	if n, err := testutil.GatherAndCount(reg); err != nil || n == 0 {
+		t.Errorf("gather: %d metrics, %v", n, err)
+	}
+}
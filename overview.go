@@ -0,0 +1,294 @@
+package nntp
+
+import (
+	"errors"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// DefaultOverviewChunk is the number of articles an OverviewIterator asks
+// for at once unless told otherwise.
+const DefaultOverviewChunk = 1000
+
+// An OverviewIterator yields the overviews of a range of articles one at
+// a time, fetching them in chunks so that huge ranges need neither one
+// huge response nor memory for all of it:
+//
+//	it := conn.NewOverviewIterator(group.Low, group.High)
+//	for it.Next() {
+//	    ov := it.Overview()
+//	    ...
+//	}
+//	if err := it.Err(); err != nil { ... }
+//
+// Lines are parsed leniently, as by ParseOverview, and malformed ones
+// are skipped rather than failing their chunk. If fetching a chunk fails,
+// Next returns false and Err reports why; calling Next again resumes with
+// that chunk, after the last overview returned, e.g. once a Session has
+// reconnected.
+type OverviewIterator struct {
+	// ChunkSize is the number of articles requested per XOVER.
+	ChunkSize int64
+
+	fetch  func(begin, end int64) ([]string, error)
+	next   int64 // first article of the next chunk
+	end    int64
+	lines  []string
+	cur    MessageOverview
+	last   int64
+	report OverviewReport
+	err    error
+}
+
+// NewOverviewIterator returns an iterator over the overviews of articles
+// begin through end in the current group.
+func (c *Conn) NewOverviewIterator(begin, end int64) *OverviewIterator {
+	return newOverviewIterator(c.overviewLines, begin, end)
+}
+
+// NewOverviewIterator is like Conn.NewOverviewIterator, with each chunk
+// retried after a lost connection.
+func (s *Session) NewOverviewIterator(begin, end int64) *OverviewIterator {
+	return newOverviewIterator(func(begin, end int64) (lines []string, err error) {
+		err = s.do(true, func(c *Conn) (err error) {
+			lines, err = c.overviewLines(begin, end)
+			return err
+		})
+		return lines, err
+	}, begin, end)
+}
+
+func newOverviewIterator(fetch func(begin, end int64) ([]string, error), begin, end int64) *OverviewIterator {
+	return &OverviewIterator{
+		ChunkSize: DefaultOverviewChunk,
+		fetch:     fetch,
+		next:      begin,
+		end:       end,
+		last:      begin - 1,
+	}
+}
+
+// Next advances to the next overview and reports whether there is one.
+func (it *OverviewIterator) Next() bool {
+	// After an error, the failed chunk is requested again.
+	it.err = nil
+	for {
+		for len(it.lines) > 0 {
+			line := it.lines[0]
+			it.lines = it.lines[1:]
+			if line == "" {
+				continue
+			}
+			ov, ok := parseOverviewLenient(line, &it.report)
+			if !ok {
+				continue
+			}
+			if ov.MessageNumber <= it.last {
+				it.report.add(line, errors.New("article number out of order"), false)
+				continue
+			}
+			it.cur, it.last = ov, ov.MessageNumber
+			return true
+		}
+		if it.next > it.end {
+			return false
+		}
+		chunk := it.ChunkSize
+		if chunk <= 0 {
+			chunk = DefaultOverviewChunk
+		}
+		end := it.next + chunk - 1
+		if end > it.end || end < it.next {
+			end = it.end
+		}
+		lines, err := it.fetch(it.next, end)
+		var terr *textproto.Error
+		if errors.As(err, &terr) && terr.Code == 423 {
+			// Some servers answer 423 for a range with no articles.
+			lines, err = nil, nil
+		}
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.lines, it.next = lines, end+1
+	}
+}
+
+// Overview returns the overview the iterator is on.
+func (it *OverviewIterator) Overview() MessageOverview {
+	return it.cur
+}
+
+// Skipped returns the number of malformed or out-of-order lines skipped
+// so far.
+func (it *OverviewIterator) Skipped() int {
+	return it.report.Dropped()
+}
+
+// Report returns the problems found in the lines read so far.
+func (it *OverviewIterator) Report() *OverviewReport {
+	return &it.report
+}
+
+// Err returns the error that stopped the iteration, or nil if it reached
+// the end of the range.
+func (it *OverviewIterator) Err() error {
+	return it.err
+}
+
+// An OverviewLineError describes a problem with one line of overview
+// data. Recovered problems were worked around and the line was kept;
+// others caused it to be dropped.
+type OverviewLineError struct {
+	Line      string
+	Err       error
+	Recovered bool
+}
+
+func (e *OverviewLineError) Error() string {
+	return e.Err.Error() + " in overview line: " + e.Line
+}
+
+// An OverviewReport lists the problems found while parsing overview data
+// leniently.
+type OverviewReport struct {
+	Errors []*OverviewLineError
+}
+
+// Dropped returns the number of lines that could not be parsed.
+func (r *OverviewReport) Dropped() int {
+	n := 0
+	for _, e := range r.Errors {
+		if !e.Recovered {
+			n++
+		}
+	}
+	return n
+}
+
+// Recovered returns the number of problems that were worked around.
+func (r *OverviewReport) Recovered() int {
+	return len(r.Errors) - r.Dropped()
+}
+
+func (r *OverviewReport) add(line string, err error, recovered bool) {
+	r.Errors = append(r.Errors, &OverviewLineError{Line: line, Err: err, Recovered: recovered})
+}
+
+// SetLenientOverview makes Overview parse leniently, as ParseOverview
+// does, dropping lines it cannot parse instead of failing. Problems are
+// logged at debug level. Use OverviewWithReport to see them.
+func (c *Conn) SetLenientOverview(on bool) {
+	c.lenientOverview = on
+}
+
+// OverviewWithReport is like Overview but always parses leniently and
+// returns a report of the problems found along with the good data.
+func (c *Conn) OverviewWithReport(begin, end int64) ([]MessageOverview, *OverviewReport, error) {
+	lines, err := c.overviewLines(begin, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	ovs, report := ParseOverview(lines)
+	return ovs, report, nil
+}
+
+// ParseOverview parses the lines of an XOVER or OVER response leniently,
+// working around common server bugs: tabs in the subject, an empty or
+// missing line count, and negative or garbled sizes, which become zero.
+// Lines that cannot be parsed at all, such as those without a valid
+// article number, are dropped. Every problem is listed in the report.
+func ParseOverview(lines []string) ([]MessageOverview, *OverviewReport) {
+	report := &OverviewReport{}
+	result := []MessageOverview{}
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		ov, ok := parseOverviewLenient(line, report)
+		if ok {
+			result = append(result, ov)
+		}
+	}
+	return result, report
+}
+
+func looksLikeMessageID(s string) bool {
+	return len(s) > 2 && s[0] == '<' && s[len(s)-1] == '>' && !strings.ContainsAny(s, " \t")
+}
+
+func parseOverviewLenient(line string, report *OverviewReport) (MessageOverview, bool) {
+	var ov MessageOverview
+	ss := strings.Split(strings.TrimRight(line, "\r\n"), "\t")
+	if len(ss) < 5 {
+		report.add(line, errors.New("too few fields"), false)
+		return ov, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(ss[0]), 10, 64)
+	if err != nil {
+		report.add(line, errors.New("bad article number "+strconv.Quote(ss[0])), false)
+		return ov, false
+	}
+	ov.MessageNumber = n
+
+	// Unescaped tabs in the subject shift the other fields right. Find the
+	// Message-ID and fold the surplus back into the subject.
+	if !looksLikeMessageID(ss[4]) {
+		for i := 5; i < len(ss); i++ {
+			if looksLikeMessageID(ss[i]) {
+				extra := i - 4
+				subject := strings.Join(ss[1:2+extra], " ")
+				ss = append([]string{ss[0], subject}, ss[2+extra:]...)
+				report.add(line, errors.New("tab in subject"), true)
+				break
+			}
+		}
+	}
+	if len(ss) < 7 {
+		report.add(line, errors.New("too few fields"), false)
+		return ov, false
+	}
+	ov.Subject = ss[1]
+	ov.From = ss[2]
+	ov.Date, _ = ParseDate(ss[3])
+	ov.MessageID = ss[4]
+	if ss[5] != "" {
+		ov.References = strings.Split(ss[5], " ")
+	} else {
+		ov.References = []string{""}
+	}
+	ov.Bytes = lenientCount(line, "byte count", ss[6], report)
+	if len(ss) > 7 {
+		ov.Lines = lenientCount(line, "line count", ss[7], report)
+		// Like the strict parser, keep everything after the line count
+		// as one field, tabs included.
+		ov.Extra = []string{}
+		if len(ss) > 8 {
+			ov.Extra = append(ov.Extra, strings.Join(ss[8:], "\t"))
+		}
+	} else {
+		report.add(line, errors.New("missing line count"), true)
+		ov.Extra = []string{}
+	}
+	return ov, true
+}
+
+// lenientCount parses a size field, using zero for empty, negative or
+// garbled values.
+func lenientCount(line, what, s string, report *OverviewReport) int {
+	v, err := strconv.Atoi(strings.TrimSpace(s))
+	switch {
+	case strings.TrimSpace(s) == "":
+		report.add(line, errors.New("empty "+what), true)
+		return 0
+	case err != nil:
+		report.add(line, errors.New("bad "+what+" "+strconv.Quote(s)), true)
+		return 0
+	case v < 0:
+		report.add(line, errors.New("negative "+what), true)
+		return 0
+	}
+	return v
+}
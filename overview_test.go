@@ -0,0 +1,127 @@
+package nntp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func ovLine(n int) string {
+	return fmt.Sprintf("%d\tsubject %d\tfrom\tMon, 01 Mar 2021 10:00:00 +0000\t<%d@x>\t\t100\t10", n, n, n)
+}
+
+func TestOverviewIterator(t *testing.T) {
+	server := "224 overview\r\n" + ovLine(1) + "\r\n" + ovLine(2) + "\r\nbroken line\r\n" + ovLine(3) + "\r\n.\r\n" +
+		"423 no articles in range\r\n" +
+		"224 overview\r\n" + ovLine(9) + "\r\n.\r\n"
+	var out bytes.Buffer
+	c := &Conn{conn: textproto.NewConn(faker{&out, strings.NewReader(server)})}
+	it := c.NewOverviewIterator(1, 9)
+	it.ChunkSize = 3
+	var got []int64
+	for it.Next() {
+		got = append(got, it.Overview().MessageNumber)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[1 2 3 9]" || it.Skipped() != 1 {
+		t.Errorf("got %v, skipped %d", got, it.Skipped())
+	}
+	if want := "XOVER 1-3\r\nXOVER 4-6\r\nXOVER 7-9\r\n"; out.String() != want {
+		t.Errorf("sent %q", out.String())
+	}
+}
+
+func TestOverviewIteratorResume(t *testing.T) {
+	var reqs []string
+	fail := true
+	fetch := func(begin, end int64) ([]string, error) {
+		reqs = append(reqs, fmt.Sprintf("%d-%d", begin, end))
+		if begin == 3 && fail {
+			fail = false
+			return nil, errors.New("connection lost")
+		}
+		var lines []string
+		for n := begin; n <= end; n++ {
+			lines = append(lines, ovLine(int(n)))
+		}
+		return lines, nil
+	}
+	it := newOverviewIterator(fetch, 1, 4)
+	it.ChunkSize = 2
+	var got []int64
+	for it.Next() {
+		got = append(got, it.Overview().MessageNumber)
+	}
+	if it.Err() == nil {
+		t.Fatal("expected an error")
+	}
+	for it.Next() {
+		got = append(got, it.Overview().MessageNumber)
+	}
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+	if fmt.Sprint(got) != "[1 2 3 4]" || fmt.Sprint(reqs) != "[1-2 3-4 3-4]" {
+		t.Errorf("got %v with requests %v", got, reqs)
+	}
+}
+
+func TestParseOverview(t *testing.T) {
+	lines := []string{
+		ovLine(1),
+		"2\tsubject\twith tab\tfrom\tMon, 01 Mar 2021 10:00:00 +0000\t<2@x>\t\t100\t10",
+		"3\ts\tfrom\tdate\t<3@x>\t\t-5\t",
+		"4\ts\tfrom\tdate\t<4@x>\t\tlots",
+		"x5\ts\tfrom\tdate\t<5@x>\t\t1\t1",
+		"6\ts",
+	}
+	ovs, report := ParseOverview(lines)
+	if len(ovs) != 4 {
+		t.Fatalf("parsed %d overviews: %+v", len(ovs), ovs)
+	}
+	if ovs[1].Subject != "subject with tab" || ovs[1].From != "from" || ovs[1].MessageID != "<2@x>" || ovs[1].Lines != 10 {
+		t.Errorf("tab in subject not recovered: %+v", ovs[1])
+	}
+	if ovs[2].Bytes != 0 || ovs[2].Lines != 0 || ovs[3].Bytes != 0 {
+		t.Errorf("bad counts not zeroed: %+v %+v", ovs[2], ovs[3])
+	}
+	// Recovered: tab, negative bytes, empty lines, bad bytes, missing lines.
+	if report.Dropped() != 2 || report.Recovered() != 5 {
+		for _, e := range report.Errors {
+			t.Log(e, e.Recovered)
+		}
+		t.Errorf("dropped %d, recovered %d", report.Dropped(), report.Recovered())
+	}
+
+	// Strict parsing still rejects the batch; lenient mode keeps the rest.
+	server := "224 overview\r\n" + strings.Join(lines, "\r\n") + "\r\n.\r\n"
+	c := &Conn{conn: textproto.NewConn(faker{&bytes.Buffer{}, strings.NewReader(server + server)})}
+	c.SetLogger(nil)
+	if _, err := c.Overview(1, 6); err == nil {
+		t.Error("strict Overview accepted bad lines")
+	}
+	c.SetLenientOverview(true)
+	if ovs, err := c.Overview(1, 6); err != nil || len(ovs) != 4 {
+		t.Errorf("lenient Overview: %d, %v", len(ovs), err)
+	}
+}
+
+func TestParseOverviewExtra(t *testing.T) {
+	line := ovLine(1) + "\tXref: news alt.test:1\tX-Other: y"
+	strict, err := parseOverviewLine(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ovs, _ := ParseOverview([]string{line})
+	if len(ovs) != 1 {
+		t.Fatalf("parsed %d overviews", len(ovs))
+	}
+	if fmt.Sprintf("%q", ovs[0].Extra) != fmt.Sprintf("%q", strict.Extra) {
+		t.Errorf("lenient Extra %q, strict %q", ovs[0].Extra, strict.Extra)
+	}
+}
@@ -0,0 +1,27 @@
+package nntp
+
+import "errors"
+
+// ErrOverviewNotFound is returned by an OverviewStore when a message-id
+// is not stored.
+var ErrOverviewNotFound = errors.New("nntp: overview not found")
+
+// An OverviewStore keeps overviews locally so they need not be fetched
+// again. Overviews are keyed by server, group and article number, and
+// indexed by message-id per server. Package nntpbolt has a file-backed
+// implementation.
+type OverviewStore interface {
+	// PutOverviews stores overviews for a group, replacing any with the
+	// same article numbers.
+	PutOverviews(server, group string, overviews []MessageOverview) error
+	// ScanOverviews calls fn for each stored overview in the group with
+	// an article number between begin and end inclusive, in order. An
+	// error from fn stops the scan and is returned.
+	ScanOverviews(server, group string, begin, end int64, fn func(MessageOverview) error) error
+	// OverviewByMessageID returns the group and overview of an article.
+	// If it was stored under several groups, any one may be returned.
+	OverviewByMessageID(server, msgid string) (string, MessageOverview, error)
+	// Expire removes the group's overviews numbered below low, typically
+	// the group's current low water mark, and returns how many went.
+	Expire(server, group string, low int64) (int, error)
+}
@@ -0,0 +1,134 @@
+package par2
+
+// Arithmetic over GF(2^16) as used by the PAR2 Reed-Solomon code.
+//
+// The field is generated by the polynomial x^16 + x^12 + x^3 + x + 1
+// (0x1100B) with 2 as the primitive element. Recovery data is treated as
+// a sequence of little-endian 16-bit words.
+
+const (
+	gfBits  = 16
+	gfSize  = 1 << gfBits
+	gfLimit = gfSize - 1
+	gfPoly  = 0x1100B
+)
+
+var (
+	gfLog [gfSize]uint16
+	gfExp [2 * gfLimit]uint16
+)
+
+func init() {
+	x := 1
+	for i := 0; i < gfLimit; i++ {
+		gfExp[i] = uint16(x)
+		gfExp[i+gfLimit] = uint16(x)
+		gfLog[x] = uint16(i)
+		x <<= 1
+		if x&gfSize != 0 {
+			x ^= gfPoly
+		}
+	}
+}
+
+func gfMul(a, b uint16) uint16 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b uint16) uint16 {
+	if a == 0 {
+		return 0
+	}
+	if b == 0 {
+		panic("par2: division by zero in GF(2^16)")
+	}
+	return gfExp[int(gfLog[a])+gfLimit-int(gfLog[b])]
+}
+
+func gfPow(a uint16, n uint32) uint16 {
+	if n == 0 {
+		return 1
+	}
+	if a == 0 {
+		return 0
+	}
+	return gfExp[uint64(gfLog[a])*uint64(n)%gfLimit]
+}
+
+// inputConstants returns the Reed-Solomon base for each of n input slices.
+// Slice i uses 2^k where k is the i-th integer coprime to 65535.
+func inputConstants(n int) []uint16 {
+	res := make([]uint16, n)
+	k := 0
+	for i := range res {
+		for k%3 == 0 || k%5 == 0 || k%17 == 0 || k%257 == 0 {
+			k++
+		}
+		res[i] = gfExp[k]
+		k++
+	}
+	return res
+}
+
+// mulAdd adds f*src to dst word by word. Both buffers must have the same
+// even length.
+func mulAdd(dst, src []byte, f uint16) {
+	if f == 0 {
+		return
+	}
+	var lo, hi [256]uint16
+	for i := 0; i < 256; i++ {
+		lo[i] = gfMul(f, uint16(i))
+		hi[i] = gfMul(f, uint16(i)<<8)
+	}
+	for i := 0; i+1 < len(src); i += 2 {
+		p := lo[src[i]] ^ hi[src[i+1]]
+		dst[i] ^= byte(p)
+		dst[i+1] ^= byte(p >> 8)
+	}
+}
+
+// invertMatrix inverts the square matrix m in place.
+func invertMatrix(m [][]uint16) error {
+	n := len(m)
+	inv := make([][]uint16, n)
+	for i := range inv {
+		inv[i] = make([]uint16, n)
+		inv[i][i] = 1
+	}
+	for col := 0; col < n; col++ {
+		pivot := -1
+		for row := col; row < n; row++ {
+			if m[row][col] != 0 {
+				pivot = row
+				break
+			}
+		}
+		if pivot < 0 {
+			return ErrSingularMatrix
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		inv[col], inv[pivot] = inv[pivot], inv[col]
+		if p := m[col][col]; p != 1 {
+			for j := 0; j < n; j++ {
+				m[col][j] = gfDiv(m[col][j], p)
+				inv[col][j] = gfDiv(inv[col][j], p)
+			}
+		}
+		for row := 0; row < n; row++ {
+			f := m[row][col]
+			if row == col || f == 0 {
+				continue
+			}
+			for j := 0; j < n; j++ {
+				m[row][j] ^= gfMul(f, m[col][j])
+				inv[row][j] ^= gfMul(f, inv[col][j])
+			}
+		}
+	}
+	copy(m, inv)
+	return nil
+}
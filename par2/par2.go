@@ -0,0 +1,288 @@
+// Package par2 reads PAR2 recovery sets and uses them to verify and repair
+// downloaded files, as described in the Parity Volume Set Specification 2.0.
+//
+// A typical download ends with the data files and one or more .par2
+// volumes in a single directory:
+//
+//   names, _ := filepath.Glob(filepath.Join(dir, "*.par2"))
+//   set, err := par2.Open(names...)
+//   ...
+//   res, err := set.Verify(dir)
+//   if res.NeedsRepair() && res.Repairable() {
+//       res, err = set.Repair(dir)
+//   }
+//
+package par2
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ErrNoMainPacket is returned when none of the volumes contained a valid
+// main packet, so the recovery set cannot be reconstructed.
+var ErrNoMainPacket = errors.New("par2: no main packet found")
+
+// ErrSingularMatrix is returned by Repair when the chosen recovery slices
+// do not form a solvable system.
+var ErrSingularMatrix = errors.New("par2: recovery matrix is singular")
+
+// A FormatError reports malformed PAR2 data.
+type FormatError string
+
+func (e FormatError) Error() string {
+	return "par2: " + string(e)
+}
+
+const headerSize = 64
+
+var packetMagic = []byte("PAR2\x00PKT")
+
+var (
+	typeMain     = packetType("PAR 2.0\x00Main")
+	typeFileDesc = packetType("PAR 2.0\x00FileDesc")
+	typeIFSC     = packetType("PAR 2.0\x00IFSC")
+	typeRecovery = packetType("PAR 2.0\x00RecvSlic")
+	typeCreator  = packetType("PAR 2.0\x00Creator")
+)
+
+func packetType(s string) (t [16]byte) {
+	copy(t[:], s)
+	return t
+}
+
+// SliceChecksum holds the checksums of one slice of a file, taken over the
+// slice padded with zeros to the full slice size.
+type SliceChecksum struct {
+	MD5   [16]byte
+	CRC32 uint32
+}
+
+// A File describes one file protected by the recovery set.
+type File struct {
+	ID      [16]byte
+	Hash    [16]byte // MD5 of the whole file
+	Hash16k [16]byte // MD5 of the first 16KiB of the file
+	Size    int64
+	Name    string
+	// Slices is nil if no IFSC packet for the file was found.
+	Slices []SliceChecksum
+}
+
+// A Set is a PAR2 recovery set assembled from one or more volumes.
+type Set struct {
+	ID        [16]byte
+	SliceSize int64
+	Creator   string
+	// Files protected by recovery data, in the order used for the
+	// Reed-Solomon computation.
+	Files []*File
+	// Recovery slices keyed by exponent.
+	Recovery map[uint32][]byte
+
+	fileIDs [][16]byte
+	files   map[[16]byte]*File
+	slices  map[[16]byte][]SliceChecksum
+	haveID  bool
+}
+
+// Open reads the named PAR2 volumes into a single Set.
+func Open(names ...string) (*Set, error) {
+	s := &Set{}
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.ReadFrom(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := s.finish(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Parse reads a single PAR2 volume.
+func Parse(r io.Reader) (*Set, error) {
+	s := &Set{}
+	if _, err := s.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	if err := s.finish(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ReadFrom adds the packets of another volume to the set. Damaged packets
+// are skipped, as are packets belonging to a different recovery set.
+// Call Open or Parse to get a usable Set; ReadFrom is for callers that
+// receive volumes incrementally and finish with Complete.
+func (s *Set) ReadFrom(r io.Reader) (int64, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return int64(len(data)), err
+	}
+	if s.files == nil {
+		s.files = map[[16]byte]*File{}
+		s.slices = map[[16]byte][]SliceChecksum{}
+		s.Recovery = map[uint32][]byte{}
+	}
+	for off := 0; ; {
+		i := bytes.Index(data[off:], packetMagic)
+		if i < 0 {
+			break
+		}
+		off += i
+		if n := s.readPacket(data[off:]); n > 0 {
+			off += n
+		} else {
+			off += len(packetMagic)
+		}
+	}
+	return int64(len(data)), nil
+}
+
+// Complete validates the packets gathered by ReadFrom and populates Files.
+func (s *Set) Complete() error {
+	return s.finish()
+}
+
+// readPacket parses the packet at the start of p and returns its length,
+// or 0 if it is truncated or corrupt.
+func (s *Set) readPacket(p []byte) int {
+	if len(p) < headerSize {
+		return 0
+	}
+	length := binary.LittleEndian.Uint64(p[8:16])
+	if length < headerSize || length%4 != 0 || length > uint64(len(p)) {
+		return 0
+	}
+	pkt := p[:length]
+	if sum := md5.Sum(pkt[32:]); !bytes.Equal(sum[:], pkt[16:32]) {
+		return 0
+	}
+	var setID, typ [16]byte
+	copy(setID[:], pkt[32:48])
+	copy(typ[:], pkt[48:64])
+	if s.haveID && setID != s.ID {
+		return int(length)
+	}
+	s.ID, s.haveID = setID, true
+	body := pkt[headerSize:]
+	switch typ {
+	case typeMain:
+		s.readMain(body)
+	case typeFileDesc:
+		s.readFileDesc(body)
+	case typeIFSC:
+		s.readIFSC(body)
+	case typeRecovery:
+		if len(body) >= 4 {
+			exp := binary.LittleEndian.Uint32(body)
+			s.Recovery[exp] = body[4:]
+		}
+	case typeCreator:
+		s.Creator = strings.TrimRight(string(body), "\x00")
+	}
+	return int(length)
+}
+
+func (s *Set) readMain(body []byte) {
+	if len(body) < 12 || (len(body)-12)%16 != 0 {
+		return
+	}
+	s.SliceSize = int64(binary.LittleEndian.Uint64(body))
+	n := int(binary.LittleEndian.Uint32(body[8:]))
+	ids := readIDs(body[12:])
+	if n > len(ids) {
+		return
+	}
+	s.fileIDs = ids[:n]
+}
+
+func (s *Set) readFileDesc(body []byte) {
+	if len(body) < 56 {
+		return
+	}
+	f := &File{
+		Size: int64(binary.LittleEndian.Uint64(body[48:56])),
+		Name: strings.TrimRight(string(body[56:]), "\x00"),
+	}
+	copy(f.ID[:], body[0:16])
+	copy(f.Hash[:], body[16:32])
+	copy(f.Hash16k[:], body[32:48])
+	s.files[f.ID] = f
+}
+
+func (s *Set) readIFSC(body []byte) {
+	if len(body) < 16 || (len(body)-16)%20 != 0 {
+		return
+	}
+	var id [16]byte
+	copy(id[:], body)
+	var sums []SliceChecksum
+	for p := body[16:]; len(p) > 0; p = p[20:] {
+		var c SliceChecksum
+		copy(c.MD5[:], p[:16])
+		c.CRC32 = binary.LittleEndian.Uint32(p[16:20])
+		sums = append(sums, c)
+	}
+	s.slices[id] = sums
+}
+
+func readIDs(p []byte) [][16]byte {
+	ids := make([][16]byte, 0, len(p)/16)
+	for ; len(p) >= 16; p = p[16:] {
+		var id [16]byte
+		copy(id[:], p)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (s *Set) finish() error {
+	if s.fileIDs == nil || s.SliceSize <= 0 || s.SliceSize%4 != 0 {
+		return ErrNoMainPacket
+	}
+	s.Files = s.Files[:0]
+	for _, id := range s.fileIDs {
+		f, ok := s.files[id]
+		if !ok {
+			return FormatError("missing file description packet")
+		}
+		if name := path.Clean(f.Name); path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return FormatError("unsafe file name " + f.Name)
+		}
+		f.Slices = s.slices[id]
+		if f.Slices != nil && int64(len(f.Slices)) != f.sliceCount(s.SliceSize) {
+			return FormatError("slice checksum count mismatch for " + f.Name)
+		}
+		s.Files = append(s.Files, f)
+	}
+	sort.Slice(s.Files, func(i, j int) bool {
+		return bytes.Compare(s.Files[i].ID[:], s.Files[j].ID[:]) < 0
+	})
+	for exp, data := range s.Recovery {
+		if int64(len(data)) != s.SliceSize {
+			delete(s.Recovery, exp)
+		}
+	}
+	return nil
+}
+
+func (f *File) sliceCount(sliceSize int64) int64 {
+	return (f.Size + sliceSize - 1) / sliceSize
+}
@@ -0,0 +1,244 @@
+package par2
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func packet(setID [16]byte, typ [16]byte, body []byte) []byte {
+	p := make([]byte, headerSize, headerSize+len(body))
+	copy(p, packetMagic)
+	binary.LittleEndian.PutUint64(p[8:], uint64(headerSize+len(body)))
+	copy(p[32:], setID[:])
+	copy(p[48:], typ[:])
+	p = append(p, body...)
+	sum := md5.Sum(p[32:])
+	copy(p[16:], sum[:])
+	return p
+}
+
+type testFile struct {
+	name string
+	data []byte
+}
+
+// buildVolume creates a PAR2 volume protecting files with nrec recovery
+// slices.
+func buildVolume(sliceSize int, files []testFile, nrec int) []byte {
+	type desc struct {
+		id   [16]byte
+		body []byte
+		data []byte
+	}
+	var descs []desc
+	for _, f := range files {
+		name := []byte(f.name)
+		for len(name)%4 != 0 {
+			name = append(name, 0)
+		}
+		h16 := md5.Sum(f.data[:minInt(len(f.data), 16384)])
+		idInput := append(h16[:], make([]byte, 8)...)
+		binary.LittleEndian.PutUint64(idInput[16:], uint64(len(f.data)))
+		idInput = append(idInput, name...)
+		d := desc{id: md5.Sum(idInput), data: f.data}
+		full := md5.Sum(f.data)
+		d.body = append(append(append(d.id[:0:0], d.id[:]...), full[:]...), h16[:]...)
+		d.body = append(d.body, idInput[16:24]...)
+		d.body = append(d.body, name...)
+		descs = append(descs, d)
+	}
+	sort.Slice(descs, func(i, j int) bool { return bytes.Compare(descs[i].id[:], descs[j].id[:]) < 0 })
+
+	main := make([]byte, 12)
+	binary.LittleEndian.PutUint64(main, uint64(sliceSize))
+	binary.LittleEndian.PutUint32(main[8:], uint32(len(descs)))
+	for _, d := range descs {
+		main = append(main, d.id[:]...)
+	}
+	setID := md5.Sum(main)
+
+	var out, slices [][]byte
+	out = append(out, packet(setID, typeMain, main))
+	for _, d := range descs {
+		out = append(out, packet(setID, typeFileDesc, d.body))
+		ifsc := append([]byte(nil), d.id[:]...)
+		for off := 0; off < len(d.data); off += sliceSize {
+			buf := make([]byte, sliceSize)
+			copy(buf, d.data[off:])
+			sum := md5.Sum(buf)
+			ifsc = append(ifsc, sum[:]...)
+			ifsc = append(ifsc, 0, 0, 0, 0)
+			binary.LittleEndian.PutUint32(ifsc[len(ifsc)-4:], crc32.ChecksumIEEE(buf))
+			slices = append(slices, buf)
+		}
+		out = append(out, packet(setID, typeIFSC, ifsc))
+	}
+	consts := inputConstants(len(slices))
+	for e := 0; e < nrec; e++ {
+		rec := make([]byte, 4+sliceSize)
+		binary.LittleEndian.PutUint32(rec, uint32(e))
+		for i, sl := range slices {
+			mulAdd(rec[4:], sl, gfPow(consts[i], uint32(e)))
+		}
+		out = append(out, packet(setID, typeRecovery, rec))
+	}
+	out = append(out, packet(setID, typeCreator, []byte("nntp test\x00\x00\x00")))
+	return bytes.Join(out, nil)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func randomBytes(r *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	r.Read(b)
+	return b
+}
+
+func TestGFInverse(t *testing.T) {
+	for _, a := range []uint16{1, 2, 3, 0x8000, 0xffff, 12345} {
+		if got := gfMul(a, gfDiv(1, a)); got != 1 {
+			t.Errorf("%d * 1/%d = %d", a, a, got)
+		}
+	}
+	if got := inputConstants(4); got[0] != 2 || got[1] != 4 || got[2] != 16 || got[3] != 128 {
+		t.Errorf("unexpected input constants %v", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	files := []testFile{{"a.bin", randomBytes(r, 1000)}, {"b.bin", randomBytes(r, 300)}}
+	vol := buildVolume(256, files, 2)
+	// Damaged packets and junk between packets must be skipped.
+	junk := append([]byte("garbage PAR2\x00PKT"), vol...)
+	junk[len(junk)-1] ^= 0xff
+
+	set, err := Parse(bytes.NewReader(junk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.SliceSize != 256 || len(set.Files) != 2 || len(set.Recovery) != 2 {
+		t.Fatalf("unexpected set: size %d, %d files, %d recovery", set.SliceSize, len(set.Files), len(set.Recovery))
+	}
+	if set.Creator != "" {
+		t.Errorf("damaged creator packet should be skipped, got %q", set.Creator)
+	}
+	for _, f := range set.Files {
+		if len(f.Slices) != int(f.sliceCount(set.SliceSize)) {
+			t.Errorf("%s: got %d slice checksums", f.Name, len(f.Slices))
+		}
+	}
+
+	if _, err := Parse(bytes.NewReader([]byte("not a par2 file"))); err != ErrNoMainPacket {
+		t.Errorf("expected ErrNoMainPacket, got %v", err)
+	}
+}
+
+func TestVerifyAndRepair(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	files := []testFile{
+		{"one.rar", randomBytes(r, 5000)},
+		{"two.rar", randomBytes(r, 4096)},
+		{"sub/three.rar", randomBytes(r, 10)},
+	}
+	dir, err := ioutil.TempDir("", "par2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, f := range files {
+		p := filepath.Join(dir, filepath.FromSlash(f.name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, f.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	volPath := filepath.Join(dir, "set.par2")
+	if err := ioutil.WriteFile(volPath, buildVolume(512, files, 6), 0644); err != nil {
+		t.Fatal(err)
+	}
+	set, err := Open(volPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := set.Verify(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.NeedsRepair() {
+		t.Fatal("intact set should not need repair")
+	}
+
+	// Corrupt two slices of one.rar, truncate two.rar and delete three.rar:
+	// 2 + 1 + 1 slices to recover.
+	one := append([]byte(nil), files[0].data...)
+	one[10] ^= 1
+	one[4999] ^= 1
+	ioutil.WriteFile(filepath.Join(dir, "one.rar"), one, 0644)
+	ioutil.WriteFile(filepath.Join(dir, "two.rar"), files[1].data[:4000], 0644)
+	os.Remove(filepath.Join(dir, "sub", "three.rar"))
+
+	res, err = set.Verify(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.MissingSlices != 4 || !res.Repairable() {
+		t.Fatalf("expected 4 repairable slices, got %d", res.MissingSlices)
+	}
+	status := map[string]FileStatus{}
+	for _, fr := range res.Files {
+		status[fr.File.Name] = fr.Status
+	}
+	if status["one.rar"] != StatusDamaged || status["two.rar"] != StatusDamaged || status["sub/three.rar"] != StatusMissing {
+		t.Errorf("unexpected statuses %v", status)
+	}
+
+	res, err = set.Repair(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.NeedsRepair() {
+		t.Fatal("set should be complete after repair")
+	}
+	for _, f := range files {
+		got, _ := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(f.name)))
+		if !bytes.Equal(got, f.data) {
+			t.Errorf("%s not restored", f.name)
+		}
+	}
+}
+
+func TestRepairInsufficient(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	files := []testFile{{"a", randomBytes(r, 2048)}}
+	dir, err := ioutil.TempDir("", "par2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	set, err := Parse(bytes.NewReader(buildVolume(512, files, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := set.Repair(dir)
+	if err == nil {
+		t.Fatal("repair should fail without enough recovery slices")
+	}
+	if res.Repairable() || res.MissingSlices != 4 {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
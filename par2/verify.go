@@ -0,0 +1,300 @@
+package par2
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FileStatus is the outcome of verifying a single file.
+type FileStatus int
+
+// File states reported by Verify.
+const (
+	StatusComplete FileStatus = iota
+	StatusDamaged
+	StatusMissing
+)
+
+func (s FileStatus) String() string {
+	switch s {
+	case StatusComplete:
+		return "complete"
+	case StatusDamaged:
+		return "damaged"
+	case StatusMissing:
+		return "missing"
+	}
+	return fmt.Sprintf("FileStatus(%d)", int(s))
+}
+
+// FileResult reports the state of one file of the set.
+type FileResult struct {
+	File   *File
+	Path   string
+	Status FileStatus
+	// Indexes of the slices of this file that failed verification.
+	BadSlices []int
+}
+
+// A Result summarizes the verification of a recovery set.
+type Result struct {
+	Files []*FileResult
+	// Number of slices that need to be reconstructed.
+	MissingSlices int
+	// Number of recovery slices available to do so.
+	RecoverySlices int
+}
+
+// NeedsRepair reports whether any file is damaged or missing.
+func (r *Result) NeedsRepair() bool {
+	for _, f := range r.Files {
+		if f.Status != StatusComplete {
+			return true
+		}
+	}
+	return false
+}
+
+// Repairable reports whether there is enough recovery data to repair the
+// set.
+func (r *Result) Repairable() bool {
+	return r.MissingSlices <= r.RecoverySlices
+}
+
+// Verify checks the files of the set found in dir against their recorded
+// checksums. Slices are only matched at their original offsets; data that
+// was shifted by insertions or deletions counts as damaged.
+func (s *Set) Verify(dir string) (*Result, error) {
+	res := &Result{RecoverySlices: len(s.Recovery)}
+	for _, f := range s.Files {
+		fr, err := s.verifyFile(dir, f)
+		if err != nil {
+			return nil, err
+		}
+		res.Files = append(res.Files, fr)
+		res.MissingSlices += len(fr.BadSlices)
+	}
+	return res, nil
+}
+
+func (s *Set) verifyFile(dir string, f *File) (*FileResult, error) {
+	fr := &FileResult{File: f, Path: filepath.Join(dir, filepath.FromSlash(f.Name))}
+	n := int(f.sliceCount(s.SliceSize))
+	fh, err := os.Open(fr.Path)
+	if os.IsNotExist(err) {
+		fr.Status = StatusMissing
+		fr.BadSlices = sequence(n)
+		return fr, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	h := md5.New()
+	size, err := io.Copy(h, fh)
+	if err != nil {
+		return nil, err
+	}
+	if size == f.Size && bytes.Equal(h.Sum(nil), f.Hash[:]) {
+		return fr, nil
+	}
+	fr.Status = StatusDamaged
+	if f.Slices == nil {
+		// Without slice checksums the whole file must be rebuilt.
+		fr.BadSlices = sequence(n)
+		return fr, nil
+	}
+	buf := make([]byte, s.SliceSize)
+	for i := 0; i < n; i++ {
+		if !s.readSlice(fh, f, i, buf) {
+			fr.BadSlices = append(fr.BadSlices, i)
+			continue
+		}
+		sum := md5.Sum(buf)
+		if sum != f.Slices[i].MD5 || crc32.ChecksumIEEE(buf) != f.Slices[i].CRC32 {
+			fr.BadSlices = append(fr.BadSlices, i)
+		}
+	}
+	return fr, nil
+}
+
+// readSlice reads slice i of f into buf, padding it with zeros. It
+// reports false if the slice is not fully present on disk.
+func (s *Set) readSlice(r io.ReaderAt, f *File, i int, buf []byte) bool {
+	off := int64(i) * s.SliceSize
+	want := f.Size - off
+	if want > s.SliceSize {
+		want = s.SliceSize
+	}
+	n, err := r.ReadAt(buf[:want], off)
+	for j := range buf[n:] {
+		buf[n+j] = 0
+	}
+	return int64(n) == want && (err == nil || err == io.EOF)
+}
+
+func sequence(n int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = i
+	}
+	return s
+}
+
+// Repair verifies the files in dir and reconstructs damaged or missing
+// slices from the recovery data, rewriting the affected files in place.
+// The returned Result reflects the state after repair.
+func (s *Set) Repair(dir string) (*Result, error) {
+	res, err := s.Verify(dir)
+	if err != nil || !res.NeedsRepair() {
+		return res, err
+	}
+	if !res.Repairable() {
+		return res, fmt.Errorf("par2: need %d recovery slices, have %d", res.MissingSlices, res.RecoverySlices)
+	}
+
+	// Files whose slices are all intact only need their length fixed.
+	for _, fr := range res.Files {
+		if fr.Status != StatusComplete && len(fr.BadSlices) == 0 {
+			if err := fixLength(fr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if res.MissingSlices == 0 {
+		return s.Verify(dir)
+	}
+
+	// Number every slice of the set in Reed-Solomon input order.
+	type slot struct {
+		fr    *FileResult
+		index int
+	}
+	var slots []slot
+	bad := map[int]bool{}
+	for _, fr := range res.Files {
+		for _, i := range fr.BadSlices {
+			bad[len(slots)+i] = true
+		}
+		for i := 0; i < int(fr.File.sliceCount(s.SliceSize)); i++ {
+			slots = append(slots, slot{fr, i})
+		}
+	}
+	consts := inputConstants(len(slots))
+
+	var exps []uint32
+	for e := range s.Recovery {
+		exps = append(exps, e)
+	}
+	sort.Slice(exps, func(i, j int) bool { return exps[i] < exps[j] })
+	exps = exps[:res.MissingSlices]
+
+	var missing []int
+	for i := range slots {
+		if bad[i] {
+			missing = append(missing, i)
+		}
+	}
+
+	// rhs[j] starts as recovery slice j and has every intact input slice's
+	// contribution removed, leaving a combination of the missing slices.
+	rhs := make([][]byte, len(exps))
+	for j, e := range exps {
+		rhs[j] = append([]byte(nil), s.Recovery[e]...)
+	}
+	buf := make([]byte, s.SliceSize)
+	var open *os.File
+	var openPath string
+	defer func() {
+		if open != nil {
+			open.Close()
+		}
+	}()
+	for i, sl := range slots {
+		if bad[i] {
+			continue
+		}
+		if sl.fr.Path != openPath {
+			if open != nil {
+				open.Close()
+			}
+			if open, err = os.Open(sl.fr.Path); err != nil {
+				return nil, err
+			}
+			openPath = sl.fr.Path
+		}
+		s.readSlice(open, sl.fr.File, sl.index, buf)
+		for j, e := range exps {
+			mulAdd(rhs[j], buf, gfPow(consts[i], e))
+		}
+	}
+
+	m := make([][]uint16, len(exps))
+	for j, e := range exps {
+		m[j] = make([]uint16, len(missing))
+		for k, i := range missing {
+			m[j][k] = gfPow(consts[i], e)
+		}
+	}
+	if err := invertMatrix(m); err != nil {
+		return res, err
+	}
+
+	for k, i := range missing {
+		for b := range buf {
+			buf[b] = 0
+		}
+		for j := range exps {
+			mulAdd(buf, rhs[j], m[k][j])
+		}
+		if err := s.writeSlice(slots[i].fr, slots[i].index, buf); err != nil {
+			return nil, err
+		}
+	}
+	return s.Verify(dir)
+}
+
+func (s *Set) writeSlice(fr *FileResult, i int, data []byte) error {
+	fh, err := openForRepair(fr)
+	if err != nil {
+		return err
+	}
+	off := int64(i) * s.SliceSize
+	n := fr.File.Size - off
+	if n > s.SliceSize {
+		n = s.SliceSize
+	}
+	if _, err = fh.WriteAt(data[:n], off); err == nil {
+		err = fh.Truncate(fr.File.Size)
+	}
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func fixLength(fr *FileResult) error {
+	fh, err := openForRepair(fr)
+	if err != nil {
+		return err
+	}
+	err = fh.Truncate(fr.File.Size)
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func openForRepair(fr *FileResult) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(fr.Path), 0755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(fr.Path, os.O_RDWR|os.O_CREATE, 0644)
+}
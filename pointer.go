@@ -0,0 +1,120 @@
+package nntp
+
+import (
+	"errors"
+	"net/textproto"
+)
+
+// An ArticlePointer identifies an article, as returned by Stat, Next and
+// Last.
+type ArticlePointer struct {
+	// Number is the article number in the current group. It is zero if
+	// the article was looked up by message-id and is not in the group.
+	Number int64
+	// MessageID includes the angle brackets.
+	MessageID string
+}
+
+// Errors for the article-selection responses of RFC 3977. Errors returned
+// by Stat, Next and Last match them with errors.Is, and also unwrap to
+// the *textproto.Error with the server's response.
+var (
+	ErrNoGroupSelected   = errors.New("nntp: no newsgroup selected")      // 412
+	ErrNoCurrentArticle  = errors.New("nntp: current article is invalid") // 420
+	ErrNoNextArticle     = errors.New("nntp: no next article")            // 421
+	ErrNoPreviousArticle = errors.New("nntp: no previous article")        // 422
+	ErrNoSuchArticle     = errors.New("nntp: no such article")            // 423, 430
+)
+
+var articleErrors = map[int]error{
+	412: ErrNoGroupSelected,
+	420: ErrNoCurrentArticle,
+	421: ErrNoNextArticle,
+	422: ErrNoPreviousArticle,
+	423: ErrNoSuchArticle,
+	430: ErrNoSuchArticle,
+}
+
+// articleError pairs a sentinel with the response it was made from.
+type articleError struct {
+	sentinel error
+	resp     *textproto.Error
+}
+
+func (e *articleError) Error() string        { return e.sentinel.Error() + ": " + e.resp.Error() }
+func (e *articleError) Is(target error) bool { return target == e.sentinel }
+func (e *articleError) Unwrap() error        { return e.resp }
+
+// articleErr maps an article-selection response to its sentinel.
+func articleErr(err error) error {
+	var terr *textproto.Error
+	if errors.As(err, &terr) {
+		if s, ok := articleErrors[terr.Code]; ok {
+			return &articleError{s, terr}
+		}
+	}
+	return err
+}
+
+// An ArticleIterator steps through the articles of the selected group
+// with NEXT, for servers that do not support OVER or XOVER. Use it like
+// a bufio.Scanner:
+//
+//   it := conn.NewArticleIterator()
+//   for it.Next() {
+//       p := it.Article()
+//       ...
+//   }
+//   if err := it.Err(); err != nil { ... }
+//
+// It starts at the current article, which GROUP sets to the first one
+// and Stat can move. No other commands may be sent on the connection
+// until the iteration is done, since they may move the current article.
+type ArticleIterator struct {
+	c       *Conn
+	started bool
+	p       ArticlePointer
+	err     error
+}
+
+// NewArticleIterator returns an iterator starting at the current article.
+func (c *Conn) NewArticleIterator() *ArticleIterator {
+	return &ArticleIterator{c: c}
+}
+
+// Next advances to the next article and reports whether there is one.
+func (it *ArticleIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	var err error
+	if !it.started {
+		it.started = true
+		it.p, err = it.c.Stat("")
+		if errors.Is(err, ErrNoCurrentArticle) {
+			// An empty group.
+			err = ErrNoNextArticle
+		}
+	} else {
+		it.p, err = it.c.Next()
+	}
+	if err != nil {
+		it.err = err
+		return false
+	}
+	return true
+}
+
+// Article returns the article the iterator is on.
+func (it *ArticleIterator) Article() ArticlePointer {
+	return it.p
+}
+
+// Err returns the error that ended the iteration, or nil if it reached
+// the end of the group.
+func (it *ArticleIterator) Err() error {
+	if errors.Is(it.err, ErrNoNextArticle) {
+		return nil
+	}
+	return it.err
+}
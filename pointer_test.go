@@ -0,0 +1,65 @@
+package nntp
+
+import (
+	"bytes"
+	"errors"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestStatPointer(t *testing.T) {
+	server := "223 7 <a@b> status\r\n" +
+		"423 no such article number\r\n" +
+		"430 no such article\r\n" +
+		"422 no previous article\r\n"
+	c := &Conn{conn: textproto.NewConn(faker{&bytes.Buffer{}, strings.NewReader(server)})}
+
+	p, err := c.Stat("7")
+	if err != nil || p != (ArticlePointer{7, "<a@b>"}) {
+		t.Errorf("Stat = %+v, %v", p, err)
+	}
+	if _, err = c.Stat("8"); !errors.Is(err, ErrNoSuchArticle) {
+		t.Errorf("Stat(8): %v", err)
+	}
+	var terr *textproto.Error
+	if !errors.As(err, &terr) || terr.Code != 423 {
+		t.Errorf("Stat(8) does not unwrap to the 423 response: %v", err)
+	}
+	if _, err = c.Stat("<x@y>"); !errors.Is(err, ErrNoSuchArticle) {
+		t.Errorf("Stat(<x@y>): %v", err)
+	}
+	if _, err = c.Last(); !errors.Is(err, ErrNoPreviousArticle) || errors.Is(err, ErrNoNextArticle) {
+		t.Errorf("Last: %v", err)
+	}
+}
+
+func TestArticleIterator(t *testing.T) {
+	server := "223 3 <3@x> status\r\n" +
+		"223 5 <5@x> next\r\n" +
+		"223 9 <9@x> next\r\n" +
+		"421 no next article\r\n"
+	var out bytes.Buffer
+	c := &Conn{conn: textproto.NewConn(faker{&out, strings.NewReader(server)})}
+	var got []int64
+	it := c.NewArticleIterator()
+	for it.Next() {
+		got = append(got, it.Article().Number)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0] != 3 || got[2] != 9 {
+		t.Errorf("walked %v", got)
+	}
+	if want := "STAT\r\nNEXT\r\nNEXT\r\nNEXT\r\n"; out.String() != want {
+		t.Errorf("sent %q", out.String())
+	}
+
+	// An empty group has no current article.
+	c = &Conn{conn: textproto.NewConn(faker{&out, strings.NewReader("420 no current article\r\n")})}
+	it = c.NewArticleIterator()
+	if it.Next() || it.Err() != nil {
+		t.Errorf("empty group: %v", it.Err())
+	}
+}
@@ -0,0 +1,150 @@
+package nntp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// A NewsSource can answer NEWNEWS and NEWGROUPS. Both *Conn and *Session
+// satisfy it.
+type NewsSource interface {
+	Date() (time.Time, error)
+	QueryNewGroups(q NewsQuery) ([]*Group, error)
+	QueryNewNews(q NewsQuery, fn func(msgid string) error) error
+}
+
+// A DateStore persists the server time of the last poll of each server.
+type DateStore interface {
+	// LastDate returns the time of the last poll, or the zero time if the
+	// server has not been polled yet.
+	LastDate(server string) (time.Time, error)
+	SetLastDate(server string, t time.Time) error
+}
+
+// A NewsPoller asks a server what arrived since the previous poll. The
+// times it sends come from the server's own DATE, saved in Dates after
+// every successful poll, so the client's clock does not matter.
+type NewsPoller struct {
+	// Server names the server in the DateStore.
+	Server string
+	Source NewsSource
+	Dates  DateStore
+	// Groups and Distributions are passed on in the NewsQuery.
+	Groups        string
+	Distributions []string
+	// NewGroup is called with each group created since the last poll. If
+	// it is nil, NEWGROUPS is not sent.
+	NewGroup func(g *Group) error
+	// NewArticle is called with the message-id of each article that
+	// arrived since the last poll. If it is nil, NEWNEWS is not sent.
+	NewArticle func(msgid string) error
+	// Initial is how far back the first poll of a server looks.
+	Initial time.Duration
+	// Overlap is how far before the last poll each poll starts, to catch
+	// articles the server filed late. Articles in the overlap are passed
+	// to NewArticle again.
+	Overlap time.Duration
+}
+
+// NewNewsPoller returns a NewsPoller whose first poll looks back a day.
+func NewNewsPoller(src NewsSource, server string, dates DateStore) *NewsPoller {
+	return &NewsPoller{
+		Server:  server,
+		Source:  src,
+		Dates:   dates,
+		Initial: 24 * time.Hour,
+	}
+}
+
+// Poll runs NEWGROUPS and NEWNEWS since the last poll and passes the
+// results to the handlers. The server's time is saved only if every
+// handler call succeeds, so a failed poll is repeated in full next time.
+func (p *NewsPoller) Poll() (groups, articles int, err error) {
+	now, err := p.Source.Date()
+	if err != nil {
+		return 0, 0, err
+	}
+	since, err := p.Dates.LastDate(p.Server)
+	if err != nil {
+		return 0, 0, err
+	}
+	if since.IsZero() {
+		since = now.Add(-p.Initial)
+	}
+	q := NewsQuery{
+		Groups:        p.Groups,
+		Since:         since.Add(-p.Overlap),
+		Distributions: p.Distributions,
+	}
+	if p.NewGroup != nil {
+		gs, err := p.Source.QueryNewGroups(q)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, g := range gs {
+			if err = p.NewGroup(g); err != nil {
+				return groups, 0, err
+			}
+			groups++
+		}
+	}
+	if p.NewArticle != nil {
+		err = p.Source.QueryNewNews(q, func(id string) error {
+			if err := p.NewArticle(id); err != nil {
+				return err
+			}
+			articles++
+			return nil
+		})
+		if err != nil {
+			return groups, articles, err
+		}
+	}
+	return groups, articles, p.Dates.SetLastDate(p.Server, now)
+}
+
+// A FileDateStore keeps poll times in a JSON file. It is safe for
+// concurrent use by one process.
+type FileDateStore struct {
+	path  string
+	mu    sync.Mutex
+	dates map[string]time.Time
+}
+
+// OpenFileDateStore loads the times in path, which need not exist yet.
+func OpenFileDateStore(path string) (*FileDateStore, error) {
+	s := &FileDateStore{path: path, dates: map[string]time.Time{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &s.dates); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LastDate implements DateStore.
+func (s *FileDateStore) LastDate(server string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dates[server], nil
+}
+
+// SetLastDate implements DateStore. The file is replaced atomically.
+func (s *FileDateStore) SetLastDate(server string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dates[server] = t.UTC()
+	data, err := json.MarshalIndent(s.dates, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
@@ -0,0 +1,81 @@
+package nntp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeNews is a NewsSource whose clock is behind the client's.
+type fakeNews struct {
+	now     time.Time
+	queries []NewsQuery
+}
+
+func (f *fakeNews) Date() (time.Time, error) {
+	return f.now, nil
+}
+
+func (f *fakeNews) QueryNewGroups(q NewsQuery) ([]*Group, error) {
+	f.queries = append(f.queries, q)
+	return []*Group{{Name: "alt.new"}}, nil
+}
+
+func (f *fakeNews) QueryNewNews(q NewsQuery, fn func(string) error) error {
+	f.queries = append(f.queries, q)
+	for _, id := range []string{"<1@x>", "<2@x>"} {
+		if err := fn(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestNewsPoller(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nntp-poll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dates.json")
+	dates, err := OpenFileDateStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := &fakeNews{now: time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)}
+	p := NewNewsPoller(src, "news.example.com", dates)
+	p.Groups = "alt.*"
+	p.Overlap = time.Minute
+	var ids []string
+	p.NewGroup = func(*Group) error { return nil }
+	p.NewArticle = func(id string) error {
+		ids = append(ids, id)
+		return nil
+	}
+
+	groups, articles, err := p.Poll()
+	if err != nil || groups != 1 || articles != 2 || len(ids) != 2 {
+		t.Fatalf("Poll = %d, %d, %v", groups, articles, err)
+	}
+	first := src.now.Add(-24*time.Hour - time.Minute)
+	if len(src.queries) != 2 || !src.queries[0].Since.Equal(first) || src.queries[1].Groups != "alt.*" {
+		t.Fatalf("first poll queries: %+v", src.queries)
+	}
+
+	// The next poll starts from the server's time, read back from disk.
+	last := src.now
+	src.now = src.now.Add(time.Hour)
+	src.queries = nil
+	if p.Dates, err = OpenFileDateStore(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = p.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if !src.queries[0].Since.Equal(last.Add(-time.Minute)) {
+		t.Errorf("second poll since %v, want %v", src.queries[0].Since, last.Add(-time.Minute))
+	}
+}
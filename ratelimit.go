@@ -0,0 +1,93 @@
+package nntp
+
+import (
+	"sync"
+	"time"
+)
+
+// A Limiter is a token bucket that refills at a fixed rate. It is safe for
+// concurrent use, so one Limiter can be shared by all connections to a
+// provider to enforce an account-wide quota. A Limiter can also pace new
+// connections by calling Wait before each dial.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a Limiter allowing perSecond tokens per second with
+// bursts of up to burst tokens. The bucket starts full. A perSecond of
+// zero or less means no limit and returns nil, which never blocks.
+func NewLimiter(perSecond float64, burst int) *Limiter {
+	if !(perSecond > 0) {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:   perSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Burst returns the bucket size, or zero for a nil Limiter.
+func (l *Limiter) Burst() int {
+	if l == nil {
+		return 0
+	}
+	return int(l.burst)
+}
+
+// Wait blocks until one token is available and takes it.
+func (l *Limiter) Wait() {
+	l.WaitN(1)
+}
+
+// WaitN blocks until n tokens have been taken. Requests larger than the
+// burst size are allowed and simply wait longer. Waiters are served in the
+// order they call WaitN.
+func (l *Limiter) WaitN(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	// Take the tokens now, going into debt if needed, so later callers
+	// queue behind this one.
+	l.tokens -= float64(n)
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// Limits throttles a connection. Nil limiters impose no limit.
+type Limits struct {
+	// Download and Upload limit bytes per second read from and written
+	// to the server.
+	Download *Limiter
+	Upload   *Limiter
+	// Commands limits commands per second.
+	Commands *Limiter
+}
+
+// SetLimits applies rate limits to the connection. Bandwidth limits are
+// enforced at the transport, so they cover all data including compressed
+// responses, but only for connections made by this package.
+func (c *Conn) SetLimits(l Limits) {
+	c.limits = l
+}
@@ -0,0 +1,69 @@
+package nntp
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(1000, 100)
+	start := time.Now()
+	l.WaitN(100) // the initial burst is free
+	if d := time.Since(start); d > 20*time.Millisecond {
+		t.Errorf("burst waited %v", d)
+	}
+
+	// Shared between goroutines, 200 more tokens take about 200ms.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.WaitN(50)
+		}()
+	}
+	wg.Wait()
+	if d := time.Since(start); d < 180*time.Millisecond || d > 400*time.Millisecond {
+		t.Errorf("200 tokens at 1000/s took %v", d)
+	}
+
+	var nilLimiter *Limiter
+	nilLimiter.Wait() // no limit, must not block or panic
+}
+
+func TestLimiterNoRate(t *testing.T) {
+	for _, rate := range []float64{0, -5} {
+		l := NewLimiter(rate, 10)
+		if l != nil {
+			t.Fatalf("NewLimiter(%v) = %+v, want nil", rate, l)
+		}
+		start := time.Now()
+		l.WaitN(1000)
+		if d := time.Since(start); d > 20*time.Millisecond {
+			t.Errorf("NewLimiter(%v) limited: 1000 tokens took %v", rate, d)
+		}
+	}
+}
+
+func TestConnDownloadLimit(t *testing.T) {
+	body := strings.Repeat("x", 60) + "\r\n"
+	server := "200 hi\r\n222 1 <a@b> body\r\n" + strings.Repeat(body, 10) + ".\r\n"
+	conn, err := newClient(faker{&bytes.Buffer{}, iotest.OneByteReader(strings.NewReader(server))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetLogger(nil)
+	// 620 bytes of body at 4000 bytes/s with a 100 byte burst: ~130ms.
+	conn.SetLimits(Limits{Download: NewLimiter(4000, 100), Commands: NewLimiter(100, 1)})
+	start := time.Now()
+	if _, err := conn.Body("1"); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Errorf("download not throttled: %v", d)
+	}
+}
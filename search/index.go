@@ -0,0 +1,209 @@
+// Package search keeps a full-text index of news articles in a bbolt
+// file. Subjects, authors and decoded bodies are indexed, and queries
+// return message-ids that can be fetched again with nntp.Conn.Article.
+//
+//   ix, err := search.Open("articles.idx")
+//   a, err := conn.Article(id)
+//   err = ix.Add(a)
+//   results, err := ix.Search(`from:alice "race condition" -windows`,
+//       search.Filter{Groups: "comp.lang.*"})
+package search
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/zeddD1abl0/nntp"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Fields that terms are indexed under. Each is a single byte in the
+// posting keys.
+const (
+	fieldSubject = 's'
+	fieldFrom    = 'f'
+	fieldBody    = 'b'
+)
+
+var (
+	docsBucket     = []byte("docs")
+	idsBucket      = []byte("ids")
+	postingsBucket = []byte("postings")
+)
+
+// A Result describes a matching article.
+type Result struct {
+	MessageID string
+	Groups    []string
+	Subject   string
+	From      string
+	Date      time.Time
+}
+
+// An Index is a full-text index stored in a file. It is safe for
+// concurrent use; only one process may open the file at a time.
+type Index struct {
+	db *bolt.DB
+}
+
+// Open opens or creates the index at path.
+func Open(path string) (*Index, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{docsBucket, idsBucket, postingsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Index{db: db}, nil
+}
+
+// Close closes the index file.
+func (ix *Index) Close() error {
+	return ix.db.Close()
+}
+
+// tokenize splits text into lower-case words of letters and digits.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func docKey(id uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], id)
+	return b[:]
+}
+
+// postingKey is the field byte, the term, a zero byte and the document id,
+// so that all documents containing a term are adjacent.
+func postingKey(field byte, term string, doc uint64) []byte {
+	return append(postingPrefix(field, term), docKey(doc)...)
+}
+
+func postingPrefix(field byte, term string) []byte {
+	k := make([]byte, 0, len(term)+10)
+	k = append(k, field)
+	k = append(k, term...)
+	return append(k, 0)
+}
+
+// Add indexes an article. It needs a Message-ID header; the groups are
+// taken from Newsgroups. Adding an article again does nothing.
+func (ix *Index) Add(a *nntp.Article) error {
+	msgid := strings.TrimSpace(a.DecodedHeader("Message-ID"))
+	if msgid == "" {
+		return errors.New("search: article has no Message-ID")
+	}
+	body, err := a.BodyText()
+	if err != nil {
+		return err
+	}
+	name, addr := nntp.ParseFrom(a.DecodedHeader("From"))
+	r := Result{
+		MessageID: msgid,
+		Subject:   a.DecodedHeader("Subject"),
+		From:      a.DecodedHeader("From"),
+	}
+	for _, g := range strings.Split(a.DecodedHeader("Newsgroups"), ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			r.Groups = append(r.Groups, g)
+		}
+	}
+	r.Date, _ = nntp.ParseDate(a.DecodedHeader("Date"))
+	doc, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	return ix.db.Update(func(tx *bolt.Tx) error {
+		ids := tx.Bucket(idsBucket)
+		if ids.Get([]byte(msgid)) != nil {
+			return nil
+		}
+		docs := tx.Bucket(docsBucket)
+		id, err := docs.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err = docs.Put(docKey(id), doc); err != nil {
+			return err
+		}
+		if err = ids.Put([]byte(msgid), docKey(id)); err != nil {
+			return err
+		}
+		postings := tx.Bucket(postingsBucket)
+		for _, f := range []struct {
+			field byte
+			text  string
+		}{
+			{fieldSubject, r.Subject},
+			{fieldFrom, name + " " + addr},
+			{fieldBody, body},
+		} {
+			// Positions of each term, as uvarint deltas.
+			pos := map[string][]byte{}
+			last := map[string]int{}
+			var buf [binary.MaxVarintLen64]byte
+			for i, t := range tokenize(f.text) {
+				n := binary.PutUvarint(buf[:], uint64(i-last[t]))
+				pos[t] = append(pos[t], buf[:n]...)
+				last[t] = i
+			}
+			for t, p := range pos {
+				if err = postings.Put(postingKey(f.field, t, id), p); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Len returns the number of indexed articles.
+func (ix *Index) Len() int {
+	n := 0
+	ix.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(docsBucket).Stats().KeyN
+		return nil
+	})
+	return n
+}
+
+// postings returns the documents containing term in field, with the
+// term's positions in each.
+func postings(tx *bolt.Tx, field byte, term string) map[uint64][]int {
+	res := map[uint64][]int{}
+	prefix := postingPrefix(field, term)
+	c := tx.Bucket(postingsBucket).Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix) && len(k) == len(prefix)+8; k, v = c.Next() {
+		var ps []int
+		p := 0
+		for len(v) > 0 {
+			d, n := binary.Uvarint(v)
+			if n <= 0 {
+				break
+			}
+			p += int(d)
+			ps = append(ps, p)
+			v = v[n:]
+		}
+		res[binary.BigEndian.Uint64(k[len(prefix):])] = ps
+	}
+	return res
+}
@@ -0,0 +1,372 @@
+package search
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/zeddD1abl0/nntp"
+	bolt "go.etcd.io/bbolt"
+)
+
+// A QueryError reports a malformed query.
+type QueryError string
+
+func (e QueryError) Error() string {
+	return "search: " + string(e)
+}
+
+// Filter restricts search results.
+type Filter struct {
+	// Groups is a wildmat matched against each article's newsgroups,
+	// e.g. "comp.*,!comp.lang.*". Empty means all groups.
+	Groups string
+	// Since and Until bound the article date. Zero means unbounded.
+	Since, Until time.Time
+	// Limit caps the number of results. Zero means no limit.
+	Limit int
+}
+
+// Search returns the articles matching query, newest first.
+//
+// A query is a list of terms, all of which must match. A term is a word,
+// or a phrase in double quotes whose words must appear in order. Terms
+// may be prefixed by a field, "subject:", "from:" or "body:"; otherwise
+// any field matches. "OR" between terms matches either, "-" or "NOT"
+// before a term excludes it, and parentheses group:
+//
+//   from:alice (deadlock OR "race condition") -windows
+func (ix *Index) Search(query string, f Filter) ([]Result, error) {
+	toks, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, QueryError("unexpected " + p.toks[p.pos].text)
+	}
+	var groups *nntp.Wildmat
+	if f.Groups != "" {
+		if groups, err = nntp.CompileWildmat(f.Groups); err != nil {
+			return nil, err
+		}
+	}
+
+	var results []Result
+	err = ix.db.View(func(tx *bolt.Tx) error {
+		docs := tx.Bucket(docsBucket)
+		for id := range n.eval(tx) {
+			var r Result
+			if err := json.Unmarshal(docs.Get(docKey(id)), &r); err != nil {
+				return err
+			}
+			if !f.Since.IsZero() && r.Date.Before(f.Since) || !f.Until.IsZero() && r.Date.After(f.Until) {
+				continue
+			}
+			if groups != nil && !anyMatch(groups, r.Groups) {
+				continue
+			}
+			results = append(results, r)
+		}
+		return nil
+	})
+	sort.Slice(results, func(i, j int) bool {
+		if !results[i].Date.Equal(results[j].Date) {
+			return results[i].Date.After(results[j].Date)
+		}
+		return results[i].MessageID < results[j].MessageID
+	})
+	if f.Limit > 0 && len(results) > f.Limit {
+		results = results[:f.Limit]
+	}
+	return results, err
+}
+
+func anyMatch(w *nntp.Wildmat, groups []string) bool {
+	for _, g := range groups {
+		if w.Match(g) {
+			return true
+		}
+	}
+	return false
+}
+
+type docSet map[uint64]bool
+
+// A node is a parsed query.
+type node interface {
+	eval(tx *bolt.Tx) docSet
+}
+
+type andNode []node
+type orNode []node
+type notNode struct{ n node }
+
+// termNode matches a word or, with several words, a phrase.
+type termNode struct {
+	fields []byte
+	words  []string
+}
+
+func (a andNode) eval(tx *bolt.Tx) docSet {
+	// Intersect the positive terms, then remove the negated ones, so a
+	// query like "go -java" need not enumerate every document.
+	var res docSet
+	var not []node
+	for _, n := range a {
+		if nn, ok := n.(notNode); ok {
+			not = append(not, nn.n)
+			continue
+		}
+		s := n.eval(tx)
+		if res == nil {
+			res = s
+			continue
+		}
+		for id := range res {
+			if !s[id] {
+				delete(res, id)
+			}
+		}
+	}
+	if res == nil {
+		res = allDocs(tx)
+	}
+	for _, n := range not {
+		for id := range n.eval(tx) {
+			delete(res, id)
+		}
+	}
+	return res
+}
+
+func (o orNode) eval(tx *bolt.Tx) docSet {
+	res := docSet{}
+	for _, n := range o {
+		for id := range n.eval(tx) {
+			res[id] = true
+		}
+	}
+	return res
+}
+
+func (n notNode) eval(tx *bolt.Tx) docSet {
+	return andNode{n}.eval(tx)
+}
+
+func allDocs(tx *bolt.Tx) docSet {
+	res := docSet{}
+	c := tx.Bucket(docsBucket).Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		res[binary.BigEndian.Uint64(k)] = true
+	}
+	return res
+}
+
+func (t termNode) eval(tx *bolt.Tx) docSet {
+	res := docSet{}
+	for _, field := range t.fields {
+		lists := make([]map[uint64][]int, len(t.words))
+		for i, w := range t.words {
+			lists[i] = postings(tx, field, w)
+		}
+		for id, first := range lists[0] {
+			if res[id] {
+				continue
+			}
+			for _, p := range first {
+				if phraseAt(lists, id, p) {
+					res[id] = true
+					break
+				}
+			}
+		}
+	}
+	return res
+}
+
+// phraseAt reports whether word i of the phrase is at position p+i in
+// document id, for every i.
+func phraseAt(lists []map[uint64][]int, id uint64, p int) bool {
+	for i := 1; i < len(lists); i++ {
+		ps := lists[i][id]
+		j := sort.SearchInts(ps, p+i)
+		if j == len(ps) || ps[j] != p+i {
+			return false
+		}
+	}
+	return true
+}
+
+type token struct {
+	kind byte // 'w' word, 'p' phrase, or one of ( ) -
+	text string
+}
+
+func lex(q string) ([]token, error) {
+	var toks []token
+	rs := []rune(q)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			toks = append(toks, token{byte(r), string(r)})
+			i++
+		case r == '-' && (i == 0 || unicode.IsSpace(rs[i-1]) || rs[i-1] == '('):
+			toks = append(toks, token{'-', "-"})
+			i++
+		case r == '"':
+			j := i + 1
+			for j < len(rs) && rs[j] != '"' {
+				j++
+			}
+			if j == len(rs) {
+				return nil, QueryError("unterminated phrase")
+			}
+			toks = append(toks, token{'p', string(rs[i+1 : j])})
+			i = j + 1
+		default:
+			j := i
+			for j < len(rs) && !unicode.IsSpace(rs[j]) && !strings.ContainsRune(`()"`, rs[j]) {
+				j++
+			}
+			toks = append(toks, token{'w', string(rs[i:j])})
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() *token {
+	if p.pos < len(p.toks) {
+		return &p.toks[p.pos]
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	var or orNode
+	for {
+		n, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, n)
+		if t := p.peek(); t == nil || t.kind != 'w' || t.text != "OR" {
+			break
+		}
+		p.pos++
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	var and andNode
+	for {
+		t := p.peek()
+		if t == nil || t.kind == ')' || t.kind == 'w' && t.text == "OR" {
+			break
+		}
+		if t.kind == 'w' && t.text == "AND" {
+			p.pos++
+			continue
+		}
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if n != nil {
+			and = append(and, n)
+		}
+	}
+	if len(and) == 0 {
+		return nil, QueryError("empty query")
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t := p.peek()
+	if t.kind == '-' || t.kind == 'w' && t.text == "NOT" {
+		p.pos++
+		if p.peek() == nil {
+			return nil, QueryError("nothing to negate")
+		}
+		n, err := p.parseUnary()
+		if err != nil || n == nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	}
+	return p.parsePrimary()
+}
+
+var fieldNames = map[string]byte{
+	"subject": fieldSubject,
+	"from":    fieldFrom,
+	"body":    fieldBody,
+}
+
+// parsePrimary parses a group or a term. It returns a nil node for a term
+// with no indexable words, such as punctuation, which is ignored.
+func (p *parser) parsePrimary() (node, error) {
+	t := p.toks[p.pos]
+	p.pos++
+	switch t.kind {
+	case '(':
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.peek(); t == nil || t.kind != ')' {
+			return nil, QueryError("missing )")
+		}
+		p.pos++
+		return n, nil
+	case ')':
+		return nil, QueryError("unexpected )")
+	}
+
+	term := termNode{fields: []byte{fieldSubject, fieldFrom, fieldBody}}
+	text := t.text
+	if t.kind == 'w' {
+		if i := strings.IndexByte(text, ':'); i > 0 {
+			if f, ok := fieldNames[strings.ToLower(text[:i])]; ok {
+				term.fields = []byte{f}
+				text = text[i+1:]
+				if text == "" {
+					// "subject:" followed by a phrase.
+					if n := p.peek(); n != nil && n.kind == 'p' {
+						text = n.text
+						p.pos++
+					}
+				}
+			}
+		}
+	}
+	term.words = tokenize(text)
+	if len(term.words) == 0 {
+		return nil, nil
+	}
+	return term, nil
+}
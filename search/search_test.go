@@ -0,0 +1,95 @@
+package search
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeddD1abl0/nntp"
+)
+
+func article(id, group, from, subject, date, body string) *nntp.Article {
+	var h nntp.OrderedHeader
+	h.Add("Message-ID", id)
+	h.Add("Newsgroups", group)
+	h.Add("From", from)
+	h.Add("Subject", subject)
+	h.Add("Date", date)
+	return &nntp.Article{Header: h.MIMEHeader(), Fields: h, Body: strings.Split(body, "\n")}
+}
+
+func TestSearch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "search")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ix, err := Open(filepath.Join(dir, "idx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+
+	for _, a := range []*nntp.Article{
+		article("<1@x>", "comp.lang.go", "Alice <alice@example.com>", "Race condition in channels",
+			"Mon, 01 Mar 2021 10:00:00 +0000", "I found a race condition\nwhen closing a channel."),
+		article("<2@x>", "comp.lang.go,comp.lang.c", "Bob <bob@example.com>", "Re: Race condition in channels",
+			"Tue, 02 Mar 2021 10:00:00 +0000", "Conditions race here.\nUse a mutex."),
+		article("<3@x>", "comp.os.windows", "Alice <alice@example.com>", "=?UTF-8?Q?D=C3=A9j=C3=A0_vu?=",
+			"Wed, 03 Mar 2021 10:00:00 +0000", "A deadlock on windows."),
+	} {
+		if err := ix.Add(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ix.Add(article("<1@x>", "comp.lang.go", "", "dup", "", "")) // ignored
+	if n := ix.Len(); n != 3 {
+		t.Errorf("Len = %d", n)
+	}
+
+	tests := []struct {
+		query string
+		f     Filter
+		want  string
+	}{
+		{"race", Filter{}, "[<2@x> <1@x>]"},
+		{`"race condition"`, Filter{}, "[<2@x> <1@x>]"},
+		{`body:"race condition"`, Filter{}, "[<1@x>]"},
+		{"from:alice", Filter{}, "[<3@x> <1@x>]"},
+		{"from:alice -windows", Filter{}, "[<1@x>]"},
+		{"from:alice NOT subject:race", Filter{}, "[<3@x>]"},
+		{"mutex OR deadlock", Filter{}, "[<3@x> <2@x>]"},
+		{"(mutex OR deadlock) alice", Filter{}, "[<3@x>]"},
+		{"déjà", Filter{}, "[<3@x>]"},
+		{"-race", Filter{}, "[<3@x>]"},
+		{"race", Filter{Groups: "comp.lang.c"}, "[<2@x>]"},
+		{"alice", Filter{Groups: "comp.*,!comp.os.*"}, "[<1@x>]"},
+		{"race", Filter{Since: time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC)}, "[<2@x>]"},
+		{"race", Filter{Limit: 1}, "[<2@x>]"},
+		{"nothing", Filter{}, "[]"},
+	}
+	for _, tt := range tests {
+		res, err := ix.Search(tt.query, tt.f)
+		if err != nil {
+			t.Errorf("%q: %v", tt.query, err)
+			continue
+		}
+		var ids []string
+		for _, r := range res {
+			ids = append(ids, r.MessageID)
+		}
+		if got := fmt.Sprint(ids); got != tt.want {
+			t.Errorf("%q %+v: got %s, want %s", tt.query, tt.f, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{`"open`, "(a", "a)", "", "-"} {
+		if _, err := ix.Search(bad, Filter{}); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package nntp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"sync"
+	"time"
+)
+
+// A Session is a connection that survives the server dropping it. When a
+// command fails because the connection was lost, the Session dials again,
+// restores the state set up through it (authentication, reader mode,
+// compression and the selected group), and retries the command if it is
+// safe to repeat.
+//
+// Only commands that only read are retried; RawPost is sent at most once.
+// The current article pointer set by Stat, Next and Last is not restored.
+// A Session may be used from multiple goroutines; commands are serialized.
+type Session struct {
+	dial func() (*Conn, error)
+
+	// MaxRetries is how many times a command or dial is retried after the
+	// connection is lost.
+	MaxRetries int
+	// MinBackoff and MaxBackoff bound the exponential delay between
+	// attempts.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// OnConnect, if set, is called for every new connection before the
+	// session state is restored, e.g. to install a logger or hooks.
+	OnConnect func(*Conn) error
+
+	mu         sync.Mutex
+	conn       *Conn
+	user, pass string
+	auth       bool
+	modeReader bool
+	compress   bool
+	group      string
+}
+
+// NewSession returns a Session that gets its connections from dial, such
+// as
+//
+//   s, err := nntp.NewSession(func() (*nntp.Conn, error) {
+//       return nntp.NewTLS("tcp", "news.example.com:563", nil)
+//   })
+//
+// The first connection is made immediately.
+func NewSession(dial func() (*Conn, error)) (*Session, error) {
+	s := &Session{
+		dial:       dial,
+		MaxRetries: 3,
+		MinBackoff: time.Second,
+		MaxBackoff: 30 * time.Second,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// IsConnectionLost reports whether err means the connection to the server
+// is gone: a network error, an unexpected EOF, or a 400 or 205 response.
+func IsConnectionLost(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+		return true
+	}
+	var terr *textproto.Error
+	if errors.As(err, &terr) {
+		return terr.Code == 400 || terr.Code == 205
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr)
+}
+
+func (s *Session) backoff(attempt int) {
+	d := s.MinBackoff << uint(attempt)
+	if d > s.MaxBackoff || d <= 0 {
+		d = s.MaxBackoff
+	}
+	time.Sleep(d)
+}
+
+// connect dials until it gets a connection with the session state
+// restored, or runs out of retries.
+func (s *Session) connect() error {
+	var err error
+	for attempt := 0; attempt <= s.MaxRetries; attempt++ {
+		if attempt > 0 {
+			s.backoff(attempt - 1)
+		}
+		var c *Conn
+		c, err = s.dial()
+		if err != nil {
+			continue
+		}
+		if err = s.restore(c); err != nil {
+			c.conn.Close()
+			if IsConnectionLost(err) {
+				continue
+			}
+			return err
+		}
+		s.conn = c
+		return nil
+	}
+	return err
+}
+
+func (s *Session) restore(c *Conn) error {
+	if s.OnConnect != nil {
+		if err := s.OnConnect(c); err != nil {
+			return err
+		}
+	}
+	if s.auth {
+		if err := c.Authenticate(s.user, s.pass); err != nil {
+			return err
+		}
+	}
+	if s.modeReader {
+		if err := c.ModeReader(); err != nil {
+			return err
+		}
+	}
+	if s.compress {
+		if err := c.SetCompression(); err != nil {
+			return err
+		}
+	}
+	if s.group != "" {
+		if _, err := c.Group(s.group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// do runs fn on the current connection, reconnecting as needed. fn is
+// repeated after a lost connection only if retry is set.
+func (s *Session) do(retry bool, fn func(c *Conn) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		if s.conn == nil {
+			if err := s.connect(); err != nil {
+				return err
+			}
+		}
+		err := fn(s.conn)
+		if !IsConnectionLost(err) {
+			return err
+		}
+		s.conn.conn.Close()
+		s.conn = nil
+		if !retry || attempt >= s.MaxRetries {
+			return err
+		}
+		s.backoff(attempt)
+	}
+}
+
+// Conn returns the current underlying connection, dialing if there is
+// none. State changed directly on it is not restored after a reconnect.
+func (s *Session) Conn() (*Conn, error) {
+	var conn *Conn
+	err := s.do(false, func(c *Conn) error {
+		conn = c
+		return nil
+	})
+	return conn, err
+}
+
+// Authenticate logs in and remembers the credentials for reconnects.
+func (s *Session) Authenticate(username, password string) error {
+	return s.do(true, func(c *Conn) error {
+		if err := c.Authenticate(username, password); err != nil {
+			return err
+		}
+		s.user, s.pass, s.auth = username, password, true
+		return nil
+	})
+}
+
+// ModeReader switches to reader mode, also after reconnects.
+func (s *Session) ModeReader() error {
+	return s.do(true, func(c *Conn) error {
+		if err := c.ModeReader(); err != nil {
+			return err
+		}
+		s.modeReader = true
+		return nil
+	})
+}
+
+// SetCompression turns on compression, also after reconnects.
+func (s *Session) SetCompression() error {
+	return s.do(true, func(c *Conn) error {
+		if err := c.SetCompression(); err != nil {
+			return err
+		}
+		s.compress = true
+		return nil
+	})
+}
+
+// Group selects a group, which is selected again after reconnects.
+func (s *Session) Group(group string) (*Group, error) {
+	var g *Group
+	err := s.do(true, func(c *Conn) (err error) {
+		if g, err = c.Group(group); err == nil {
+			s.group = group
+		}
+		return err
+	})
+	return g, err
+}
+
+// Article is like Conn.Article, retried after a lost connection.
+func (s *Session) Article(id string) (*Article, error) {
+	var a *Article
+	err := s.do(true, func(c *Conn) (err error) {
+		a, err = c.Article(id)
+		return err
+	})
+	return a, err
+}
+
+// ArticleText is like Conn.ArticleText, retried after a lost connection.
+func (s *Session) ArticleText(id string) ([]string, error) {
+	var lines []string
+	err := s.do(true, func(c *Conn) (err error) {
+		lines, err = c.ArticleText(id)
+		return err
+	})
+	return lines, err
+}
+
+// Head is like Conn.Head, retried after a lost connection.
+func (s *Session) Head(id string) (*Article, error) {
+	var a *Article
+	err := s.do(true, func(c *Conn) (err error) {
+		a, err = c.Head(id)
+		return err
+	})
+	return a, err
+}
+
+// HeadText is like Conn.HeadText, retried after a lost connection.
+func (s *Session) HeadText(id string) ([]string, error) {
+	var lines []string
+	err := s.do(true, func(c *Conn) (err error) {
+		lines, err = c.HeadText(id)
+		return err
+	})
+	return lines, err
+}
+
+// Body is like Conn.Body, retried after a lost connection.
+func (s *Session) Body(id string) ([]string, error) {
+	var lines []string
+	err := s.do(true, func(c *Conn) (err error) {
+		lines, err = c.Body(id)
+		return err
+	})
+	return lines, err
+}
+
+// Overview is like Conn.Overview, retried after a lost connection.
+func (s *Session) Overview(begin, end int64) ([]MessageOverview, error) {
+	var res []MessageOverview
+	err := s.do(true, func(c *Conn) (err error) {
+		res, err = c.Overview(begin, end)
+		return err
+	})
+	return res, err
+}
+
+// Stat is like Conn.Stat, retried after a lost connection.
+func (s *Session) Stat(id string) (ArticlePointer, error) {
+	var p ArticlePointer
+	err := s.do(true, func(c *Conn) (err error) {
+		p, err = c.Stat(id)
+		return err
+	})
+	return p, err
+}
+
+// Capabilities is like Conn.Capabilities, retried after a lost connection.
+func (s *Session) Capabilities() ([]string, error) {
+	var lines []string
+	err := s.do(true, func(c *Conn) (err error) {
+		lines, err = c.Capabilities()
+		return err
+	})
+	return lines, err
+}
+
+// Date is like Conn.Date, retried after a lost connection.
+func (s *Session) Date() (time.Time, error) {
+	var t time.Time
+	err := s.do(true, func(c *Conn) (err error) {
+		t, err = c.Date()
+		return err
+	})
+	return t, err
+}
+
+// List is like Conn.List, retried after a lost connection.
+func (s *Session) List(a ...string) ([]string, error) {
+	var lines []string
+	err := s.do(true, func(c *Conn) (err error) {
+		lines, err = c.List(a...)
+		return err
+	})
+	return lines, err
+}
+
+// NewGroups is like Conn.NewGroups, retried after a lost connection.
+func (s *Session) NewGroups(since time.Time) ([]*Group, error) {
+	var groups []*Group
+	err := s.do(true, func(c *Conn) (err error) {
+		groups, err = c.NewGroups(since)
+		return err
+	})
+	return groups, err
+}
+
+// NewNews is like Conn.NewNews, retried after a lost connection.
+func (s *Session) NewNews(group string, since time.Time) ([]string, error) {
+	var ids []string
+	err := s.do(true, func(c *Conn) (err error) {
+		ids, err = c.NewNews(group, since)
+		return err
+	})
+	return ids, err
+}
+
+// RawPost is like Conn.RawPost. It reconnects first if needed but is not
+// retried, since the server may already have accepted the article.
+func (s *Session) RawPost(r io.Reader) error {
+	return s.do(false, func(c *Conn) error {
+		return c.RawPost(r)
+	})
+}
+
+// IHave is like Conn.IHave, retried after a lost connection. Offering an
+// article again is safe, since a server that already took it answers 435.
+// The article is read into memory first so it can be sent again.
+func (s *Session) IHave(msgid string, r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return s.do(true, func(c *Conn) error {
+		return c.IHave(msgid, bytes.NewReader(data))
+	})
+}
+
+// Quit ends the session and closes the connection.
+func (s *Session) Quit() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Quit()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,92 @@
+package nntp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestSessionReconnect(t *testing.T) {
+	servers := []string{
+		"200 hi\r\n381 pass\r\n281 ok\r\n211 10 1 10 alt.test\r\n" +
+			"400 idle timeout\r\n",
+		"200 hi again\r\n381 pass\r\n281 ok\r\n211 10 1 10 alt.test\r\n" +
+			"222 1 <a@b> body\r\nhello\r\n.\r\n",
+	}
+	var cmds []*bytes.Buffer
+	dial := func() (*Conn, error) {
+		if len(servers) == 0 {
+			return nil, errors.New("no more servers")
+		}
+		var buf bytes.Buffer
+		cmds = append(cmds, &buf)
+		c, err := newClient(faker{&buf, bufio.NewReader(strings.NewReader(servers[0]))})
+		servers = servers[1:]
+		if c != nil {
+			c.SetLogger(nil)
+		}
+		return c, err
+	}
+	s, err := NewSession(dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.MinBackoff, s.MaxBackoff = 0, 0
+
+	if err := s.Authenticate("bob", "pw"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Group("alt.test"); err != nil {
+		t.Fatal(err)
+	}
+	body, err := s.Body("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) != 1 || body[0] != "hello" {
+		t.Errorf("unexpected body %v", body)
+	}
+
+	if len(cmds) != 2 {
+		t.Fatalf("expected 2 connections, got %d", len(cmds))
+	}
+	want := "AUTHINFO USER bob\r\nAUTHINFO PASS pw\r\nGROUP alt.test\r\nBODY 1\r\n"
+	if got := cmds[1].String(); got != want {
+		t.Errorf("second connection got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSessionPostNotRetried(t *testing.T) {
+	dials := 0
+	dial := func() (*Conn, error) {
+		dials++
+		c, err := newClient(faker{&bytes.Buffer{}, bufio.NewReader(strings.NewReader("200 hi\r\n"))})
+		if c != nil {
+			c.SetLogger(nil)
+		}
+		return c, err
+	}
+	s, err := NewSession(dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.MinBackoff, s.MaxBackoff = 0, 0
+	if err := s.RawPost(strings.NewReader("Subject: x\n\nbody\n")); !IsConnectionLost(err) {
+		t.Fatalf("expected lost connection, got %v", err)
+	}
+	if dials != 1 {
+		t.Errorf("POST was retried: %d dials", dials)
+	}
+}
+
+func TestIsConnectionLost(t *testing.T) {
+	if IsConnectionLost(&textproto.Error{Code: 430, Msg: "no such article"}) {
+		t.Error("430 is not a lost connection")
+	}
+	if !IsConnectionLost(&textproto.Error{Code: 400, Msg: "timeout"}) {
+		t.Error("400 is a lost connection")
+	}
+}
@@ -0,0 +1,224 @@
+package nntp
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// An OverviewSource can select groups and fetch overviews. Both *Conn and
+// *Session satisfy it.
+type OverviewSource interface {
+	Group(group string) (*Group, error)
+	Overview(begin, end int64) ([]MessageOverview, error)
+}
+
+// A Mark records how far a group has been synchronized.
+type Mark struct {
+	// High is the last article number fetched.
+	High int64
+	// Low is the group's low water mark at the last sync.
+	Low int64
+}
+
+// A MarkStore persists high-water marks per server and group.
+type MarkStore interface {
+	// Mark returns the mark for a group, or a zero Mark if the group has
+	// not been synchronized yet.
+	Mark(server, group string) (Mark, error)
+	SetMark(server, group string, m Mark) error
+}
+
+// A Syncer fetches the overviews of articles that arrived in a group since
+// the last sync. Progress is saved to Marks after every chunk, so an
+// interrupted sync resumes where it stopped.
+type Syncer struct {
+	// Server names the server in the MarkStore, since article numbers are
+	// only meaningful per server.
+	Server string
+	Source OverviewSource
+	Marks  MarkStore
+	// Handler is called with each chunk of new overviews, in article
+	// number order. If it returns an error the sync stops and the chunk
+	// is fetched again next time. It may be nil if Store is set.
+	Handler func(group string, overviews []MessageOverview) error
+	// Store, if set, receives each chunk before Handler is called, and
+	// overviews below the group's low water mark are expired from it.
+	Store OverviewStore
+	// ChunkSize is the largest article range requested at once.
+	ChunkSize int64
+	// Backfill limits how many of the most recent articles are fetched
+	// the first time a group is synchronized. Zero fetches all of them.
+	Backfill int64
+}
+
+// NewSyncer returns a Syncer with a chunk size of 1000 articles.
+func NewSyncer(src OverviewSource, server string, marks MarkStore, handler func(group string, overviews []MessageOverview) error) *Syncer {
+	return &Syncer{
+		Server:    server,
+		Source:    src,
+		Marks:     marks,
+		Handler:   handler,
+		ChunkSize: 1000,
+	}
+}
+
+// Sync selects the group and passes the overviews of all articles after
+// its mark to the Handler. It returns the number of overviews passed.
+//
+// If the group's high water mark has dropped below the saved mark, the
+// server has renumbered the group and the whole group is fetched again.
+// If the low water mark has moved past the saved mark, the articles in
+// between have expired and are skipped.
+func (s *Syncer) Sync(group string) (int, error) {
+	g, err := s.Source.Group(group)
+	if err != nil {
+		return 0, err
+	}
+	m, err := s.Marks.Mark(s.Server, group)
+	if err != nil {
+		return 0, err
+	}
+	next := m.High + 1
+	renumbered := g.High < m.High
+	switch {
+	case m.High == 0 && s.Backfill > 0 && g.High-s.Backfill+1 > g.Low:
+		next = g.High - s.Backfill + 1
+	case renumbered:
+		next = g.Low
+	}
+	if next < g.Low {
+		next = g.Low
+	}
+	if s.Store != nil {
+		low := g.Low
+		if renumbered {
+			// Old numbers are meaningless now.
+			low = m.High + 1
+		}
+		if _, err = s.Store.Expire(s.Server, group, low); err != nil {
+			return 0, err
+		}
+	}
+	if next < 1 {
+		next = 1
+	}
+
+	chunk := s.ChunkSize
+	if chunk <= 0 {
+		chunk = 1000
+	}
+	n := 0
+	for ; next <= g.High; next += chunk {
+		end := next + chunk - 1
+		if end > g.High {
+			end = g.High
+		}
+		ovs, err := s.Source.Overview(next, end)
+		var terr *textproto.Error
+		if errors.As(err, &terr) && terr.Code == 423 {
+			// No articles in this range.
+			ovs, err = nil, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if len(ovs) > 0 {
+			if s.Store != nil {
+				if err = s.Store.PutOverviews(s.Server, group, ovs); err != nil {
+					return n, err
+				}
+			}
+			if s.Handler != nil {
+				if err = s.Handler(group, ovs); err != nil {
+					return n, err
+				}
+			}
+			n += len(ovs)
+		}
+		if err = s.Marks.SetMark(s.Server, group, Mark{High: end, Low: g.Low}); err != nil {
+			return n, err
+		}
+	}
+	if renumbered && g.High < g.Low {
+		// Renumbered and now empty; forget the old mark.
+		return n, s.Marks.SetMark(s.Server, group, Mark{High: g.High, Low: g.Low})
+	}
+	return n, nil
+}
+
+// A FileMarkStore keeps marks in a JSON file. It is safe for concurrent
+// use by one process.
+type FileMarkStore struct {
+	path  string
+	mu    sync.Mutex
+	marks map[string]map[string]Mark
+}
+
+// OpenFileMarkStore loads the marks in path, which need not exist yet.
+func OpenFileMarkStore(path string) (*FileMarkStore, error) {
+	s := &FileMarkStore{path: path, marks: map[string]map[string]Mark{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &s.marks); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Mark implements MarkStore.
+func (s *FileMarkStore) Mark(server, group string) (Mark, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.marks[server][group], nil
+}
+
+// SetMark implements MarkStore. The file is replaced atomically.
+func (s *FileMarkStore) SetMark(server, group string, m Mark) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.marks[server] == nil {
+		s.marks[server] = map[string]Mark{}
+	}
+	s.marks[server][group] = m
+	data, err := json.MarshalIndent(s.marks, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// writeFileAtomic replaces path with data through a temporary file, so
+// that readers see either the old or the new contents. An existing file
+// keeps its permissions; a new one is readable only by its owner.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		if fi, serr := os.Stat(path); serr == nil {
+			os.Chmod(tmp.Name(), fi.Mode().Perm())
+		}
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package nntp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeSource serves overviews for the articles low..high.
+type fakeSource struct {
+	low, high int64
+	requests  []string
+}
+
+func (f *fakeSource) Group(name string) (*Group, error) {
+	return &Group{Name: name, Low: f.low, High: f.high, Count: f.high - f.low + 1}, nil
+}
+
+func (f *fakeSource) Overview(begin, end int64) ([]MessageOverview, error) {
+	f.requests = append(f.requests, fmt.Sprintf("%d-%d", begin, end))
+	var res []MessageOverview
+	for n := begin; n <= end; n++ {
+		if n >= f.low && n <= f.high {
+			res = append(res, MessageOverview{MessageNumber: n})
+		}
+	}
+	return res, nil
+}
+
+func TestSyncer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "marks.json")
+	marks, err := OpenFileMarkStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := &fakeSource{low: 5, high: 25}
+	var got []int64
+	s := NewSyncer(src, "news.example.com", marks, func(group string, ovs []MessageOverview) error {
+		for _, ov := range ovs {
+			got = append(got, ov.MessageNumber)
+		}
+		return nil
+	})
+	s.ChunkSize = 10
+
+	sync := func(wantN int, wantReqs string) {
+		t.Helper()
+		got, src.requests = nil, nil
+		n, err := s.Sync("alt.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != wantN || len(got) != wantN {
+			t.Errorf("synced %d (%d handled), want %d", n, len(got), wantN)
+		}
+		if reqs := fmt.Sprint(src.requests); reqs != wantReqs {
+			t.Errorf("requests %s, want %s", reqs, wantReqs)
+		}
+	}
+
+	sync(21, "[5-14 15-24 25-25]")
+	sync(0, "[]")
+
+	// New articles; the mark survives reopening the store.
+	src.high = 30
+	if s.Marks, err = OpenFileMarkStore(path); err != nil {
+		t.Fatal(err)
+	}
+	sync(5, "[26-30]")
+
+	// Expiry moved the low water mark past our mark.
+	src.low, src.high = 40, 42
+	sync(3, "[40-42]")
+
+	// Renumbered: high dropped below our mark.
+	src.low, src.high = 1, 3
+	sync(3, "[1-3]")
+	if m, _ := s.Marks.Mark("news.example.com", "alt.test"); m != (Mark{High: 3, Low: 1}) {
+		t.Errorf("mark %+v", m)
+	}
+}
@@ -0,0 +1,402 @@
+// Package thread arranges articles into conversation threads using the
+// algorithm described by Jamie Zawinski at https://www.jwz.org/doc/threading.html.
+//
+// Messages are added one at a time to a Threader, which keeps the
+// parent/child relations learned from References headers. Threads builds
+// the displayable tree on demand, so a newsreader can add overviews as
+// they arrive and re-render the affected thread.
+package thread
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zeddD1abl0/nntp"
+)
+
+// A Message is the information needed to thread one article.
+type Message struct {
+	ID         string
+	Subject    string
+	Date       time.Time
+	References []string
+	// Overview is set for messages created by FromOverview.
+	Overview *nntp.MessageOverview
+	// Article is set for messages created by FromArticle.
+	Article *nntp.Article
+}
+
+// FromOverview returns the Message for an overview line. Overviews of
+// articles without References hold a single empty id, which is dropped.
+func FromOverview(ov *nntp.MessageOverview) *Message {
+	var refs []string
+	for _, ref := range ov.References {
+		if ref = strings.TrimSpace(ref); ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+	return &Message{
+		ID:         ov.MessageID,
+		Subject:    ov.Subject,
+		Date:       ov.Date,
+		References: refs,
+		Overview:   ov,
+	}
+}
+
+// FromArticle returns the Message for an article's headers. When the
+// References header is absent, the first message-id of In-Reply-To is
+// used as the parent.
+func FromArticle(a *nntp.Article) *Message {
+	m := &Message{
+		ID:         strings.TrimSpace(a.HeaderValue("Message-Id")),
+		Subject:    a.HeaderValue("Subject"),
+		References: messageIDs(a.HeaderValue("References")),
+		Article:    a,
+	}
+	if len(m.References) == 0 {
+		if ids := messageIDs(a.HeaderValue("In-Reply-To")); len(ids) > 0 {
+			m.References = ids[:1]
+		}
+	}
+	m.Date, _ = nntp.ParseDate(a.HeaderValue("Date"))
+	return m
+}
+
+// messageIDs extracts the <...> message-ids from a header value.
+func messageIDs(s string) []string {
+	var ids []string
+	for {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			return ids
+		}
+		j := strings.IndexByte(s[i:], '>')
+		if j < 0 {
+			return ids
+		}
+		ids = append(ids, s[i:i+j+1])
+		s = s[i+j+1:]
+	}
+}
+
+// A Node is one position in a thread tree. Message is nil for a
+// placeholder standing in for an article that was referenced but never
+// seen, or for a synthetic root grouping threads with the same subject.
+type Node struct {
+	Message  *Message
+	Children []*Node
+}
+
+// Date returns the date of the earliest message in the subtree.
+func (n *Node) Date() time.Time {
+	var d time.Time
+	if n.Message != nil {
+		d = n.Message.Date
+	}
+	for _, c := range n.Children {
+		if cd := c.Date(); !cd.IsZero() && (d.IsZero() || cd.Before(d)) {
+			d = cd
+		}
+	}
+	return d
+}
+
+// Walk calls fn for the node and each of its descendants in display
+// order, passing the nesting depth.
+func (n *Node) Walk(fn func(n *Node, depth int)) {
+	n.walk(fn, 0)
+}
+
+func (n *Node) walk(fn func(*Node, int), depth int) {
+	fn(n, depth)
+	for _, c := range n.Children {
+		c.walk(fn, depth+1)
+	}
+}
+
+// container is the bookkeeping record for one message-id.
+type container struct {
+	id       string
+	message  *Message
+	parent   *container
+	children []*container
+}
+
+func (c *container) hasDescendant(d *container) bool {
+	for ; d != nil; d = d.parent {
+		if d == c {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *container) removeChild(child *container) {
+	for i, x := range c.children {
+		if x == child {
+			c.children = append(c.children[:i], c.children[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *container) setParent(p *container) {
+	if c.parent == p {
+		return
+	}
+	if c.parent != nil {
+		c.parent.removeChild(c)
+	}
+	c.parent = p
+	if p != nil {
+		p.children = append(p.children, c)
+	}
+}
+
+// A Threader accumulates messages and builds threads from them. The zero
+// value is ready to use. A Threader is not safe for concurrent use.
+type Threader struct {
+	ids map[string]*container
+	// Containers for messages without an id or with an id already taken.
+	extra []*container
+}
+
+// Add records a message. Adding a message whose id has already been seen
+// keeps both; the later one is threaded as if it had a unique id.
+func (t *Threader) Add(m *Message) {
+	if t.ids == nil {
+		t.ids = map[string]*container{}
+	}
+	var c *container
+	if old := t.ids[m.ID]; m.ID == "" || old != nil && old.message != nil {
+		c = &container{id: m.ID}
+		t.extra = append(t.extra, c)
+	} else {
+		c = t.lookup(m.ID)
+	}
+	c.message = m
+
+	// Link the references together in order, without overriding links
+	// learned earlier or creating loops.
+	var prev *container
+	for _, ref := range m.References {
+		if ref == "" {
+			continue
+		}
+		r := t.lookup(ref)
+		if prev != nil && r.parent == nil && r != prev && !r.hasDescendant(prev) {
+			r.setParent(prev)
+		}
+		prev = r
+	}
+	// The message's own References are authoritative for its parent.
+	if prev != nil && c.hasDescendant(prev) {
+		prev = nil
+	}
+	c.setParent(prev)
+}
+
+func (t *Threader) lookup(id string) *container {
+	c := t.ids[id]
+	if c == nil {
+		c = &container{id: id}
+		t.ids[id] = c
+	}
+	return c
+}
+
+// Len returns the number of messages added.
+func (t *Threader) Len() int {
+	n := len(t.extra)
+	for _, c := range t.ids {
+		if c.message != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Threads returns the current thread trees, oldest first. Placeholders
+// with no messages below them are pruned, a placeholder with a single
+// child is replaced by that child, and root-level threads whose subjects
+// match apart from "Re:" prefixes are gathered together.
+func (t *Threader) Threads() []*Node {
+	var roots []*Node
+	for _, c := range t.roots() {
+		roots = append(roots, prune(c, true)...)
+	}
+	roots = gatherSubjects(roots)
+	sortNodes(roots)
+	return roots
+}
+
+// Thread returns the thread containing the message with the given id, or
+// nil if it is unknown.
+func (t *Threader) Thread(id string) *Node {
+	if t.ids[id] == nil {
+		return nil
+	}
+	for _, n := range t.Threads() {
+		found := false
+		n.Walk(func(x *Node, _ int) {
+			if x.Message != nil && x.Message.ID == id {
+				found = true
+			}
+		})
+		if found {
+			return n
+		}
+	}
+	return nil
+}
+
+// roots returns the root container of every tree, in a stable order.
+func (t *Threader) roots() []*container {
+	ids := make([]string, 0, len(t.ids))
+	for id := range t.ids {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	all := make([]*container, 0, len(ids)+len(t.extra))
+	for _, id := range ids {
+		all = append(all, t.ids[id])
+	}
+	all = append(all, t.extra...)
+
+	var res []*container
+	seen := map[*container]bool{}
+	for _, c := range all {
+		for c.parent != nil {
+			c = c.parent
+		}
+		if !seen[c] {
+			seen[c] = true
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// prune converts the container tree rooted at c into nodes, dropping
+// empty containers as described in step 4 of the algorithm.
+func prune(c *container, root bool) []*Node {
+	var children []*Node
+	for _, ch := range c.children {
+		children = append(children, prune(ch, false)...)
+	}
+	if c.message != nil {
+		return []*Node{{Message: c.message, Children: children}}
+	}
+	if len(children) == 0 {
+		return nil
+	}
+	// Promote the children of an empty container, except that a root
+	// keeps its placeholder unless it has only one child.
+	if !root || len(children) == 1 {
+		return children
+	}
+	return []*Node{{Children: children}}
+}
+
+// gatherSubjects merges root threads that share a base subject.
+func gatherSubjects(roots []*Node) []*Node {
+	table := map[string]*Node{}
+	for _, n := range roots {
+		subj, reply := nodeSubject(n)
+		if subj == "" {
+			continue
+		}
+		old := table[subj]
+		if old == nil ||
+			(n.Message == nil && old.Message != nil) ||
+			(old.Message != nil && n.Message != nil && isReplyNode(old) && !reply) {
+			table[subj] = n
+		}
+	}
+
+	var res []*Node
+	for _, n := range roots {
+		subj, _ := nodeSubject(n)
+		dst := table[subj]
+		if subj == "" || dst == nil || dst == n {
+			res = append(res, n)
+			continue
+		}
+		switch {
+		case dst.Message == nil && n.Message == nil:
+			dst.Children = append(dst.Children, n.Children...)
+		case dst.Message == nil:
+			dst.Children = append(dst.Children, n)
+		case n.Message == nil:
+			// Cannot happen: an empty root would have taken the table slot.
+			res = append(res, n)
+		case !isReplyNode(dst) && isReplyNode(n):
+			dst.Children = append(dst.Children, n)
+		default:
+			// Siblings under a new placeholder that takes dst's place.
+			moved := &Node{Message: dst.Message, Children: dst.Children}
+			dst.Message = nil
+			dst.Children = []*Node{moved, n}
+		}
+	}
+	return res
+}
+
+func nodeSubject(n *Node) (string, bool) {
+	m := n.Message
+	if m == nil && len(n.Children) > 0 {
+		m = n.Children[0].Message
+	}
+	if m == nil {
+		return "", false
+	}
+	return BaseSubject(m.Subject)
+}
+
+func isReplyNode(n *Node) bool {
+	_, reply := nodeSubject(n)
+	return reply
+}
+
+func sortNodes(nodes []*Node) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].Date().Before(nodes[j].Date())
+	})
+	for _, n := range nodes {
+		sortNodes(n.Children)
+	}
+}
+
+// BaseSubject strips reply and forward prefixes such as "Re:", "RE[2]:"
+// and "Fwd:" from subject, and reports whether any were present. The
+// result is lowercased with runs of white space collapsed, for use as a
+// comparison key.
+func BaseSubject(subject string) (string, bool) {
+	s := strings.Join(strings.Fields(subject), " ")
+	reply := false
+	for {
+		lower := strings.ToLower(s)
+		stripped := false
+		for _, p := range []string{"re", "fwd", "fw", "aw", "sv"} {
+			if !strings.HasPrefix(lower, p) {
+				continue
+			}
+			rest := lower[len(p):]
+			if strings.HasPrefix(rest, "[") {
+				if i := strings.IndexByte(rest, ']'); i > 0 {
+					rest = rest[i+1:]
+				}
+			}
+			if strings.HasPrefix(rest, ":") {
+				s = strings.TrimSpace(s[len(s)-len(rest)+1:])
+				reply, stripped = true, true
+				break
+			}
+		}
+		if !stripped {
+			return strings.ToLower(s), reply
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package thread
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeddD1abl0/nntp"
+)
+
+var base = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func msg(id, subject string, minute int, refs ...string) *Message {
+	return &Message{
+		ID:         id,
+		Subject:    subject,
+		Date:       base.Add(time.Duration(minute) * time.Minute),
+		References: refs,
+	}
+}
+
+// render draws the threads one node per line, indented by depth, with
+// "*" for placeholders.
+func render(nodes []*Node) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		n.Walk(func(n *Node, depth int) {
+			name := "*"
+			if n.Message != nil {
+				name = n.Message.ID
+			}
+			fmt.Fprintf(&b, "%s%s\n", strings.Repeat("  ", depth), name)
+		})
+	}
+	return b.String()
+}
+
+func TestThreads(t *testing.T) {
+	var th Threader
+	th.Add(msg("a", "Go generics", 0))
+	th.Add(msg("b", "Re: Go generics", 1, "a"))
+	th.Add(msg("c", "Re: Go generics", 2, "a", "b"))
+	// Reply to a parent we never saw, whose own parent is a.
+	th.Add(msg("e", "Re: Go generics", 4, "a", "missing"))
+	// Two replies to an unseen root: kept under a placeholder.
+	th.Add(msg("f", "Re: Lost thread", 5, "gone"))
+	th.Add(msg("g", "Re: Lost thread", 6, "gone"))
+	// Orphaned reply gathered by subject with its original.
+	th.Add(msg("h", "Weekly meeting", 7))
+	th.Add(msg("i", "RE: weekly  meeting", 8, "nowhere"))
+
+	got := render(th.Threads())
+	want := `a
+  b
+    c
+  e
+*
+  f
+  g
+h
+  i
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if th.Len() != 8 {
+		t.Errorf("Len = %d, want 8", th.Len())
+	}
+
+	// New messages slot into the existing tree.
+	th.Add(msg("missing", "Re: Go generics", 3, "a"))
+	th.Add(msg("gone", "Lost thread", -1))
+	got = render([]*Node{th.Thread("gone"), th.Thread("c")})
+	want = `gone
+  f
+  g
+a
+  b
+    c
+  missing
+    e
+`
+	if got != want {
+		t.Errorf("after update got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLoopsAndDuplicates(t *testing.T) {
+	var th Threader
+	th.Add(msg("a", "x", 0, "b"))
+	th.Add(msg("b", "y", 1, "a"))
+	th.Add(msg("a", "dup", 2))
+	th.Add(msg("s", "self", 3, "s"))
+	n := 0
+	for _, root := range th.Threads() {
+		root.Walk(func(*Node, int) { n++ })
+	}
+	if n != 4 {
+		t.Errorf("expected 4 nodes, got %d:\n%s", n, render(th.Threads()))
+	}
+}
+
+func TestBaseSubject(t *testing.T) {
+	tests := []struct {
+		in    string
+		want  string
+		reply bool
+	}{
+		{"Hello", "hello", false},
+		{"Re: Hello", "hello", true},
+		{"RE[2]: Re:  Fwd: Hello  World", "hello world", true},
+		{"Reply all", "reply all", false},
+		{"Aw: Sv: x", "x", true},
+	}
+	for _, tt := range tests {
+		got, reply := BaseSubject(tt.in)
+		if got != tt.want || reply != tt.reply {
+			t.Errorf("BaseSubject(%q) = %q, %v; want %q, %v", tt.in, got, reply, tt.want, tt.reply)
+		}
+	}
+}
+
+func TestFromArticle(t *testing.T) {
+	a := &nntp.Article{Header: map[string][]string{
+		"Message-Id":  {"<c@x>"},
+		"Subject":     {"Re: hi"},
+		"In-Reply-To": {"<b@x> (Someone)"},
+		"Date":        {"Sat, 18 Oct 2003 18:00:00 +0000"},
+	}}
+	m := FromArticle(a)
+	if m.ID != "<c@x>" || len(m.References) != 1 || m.References[0] != "<b@x>" || m.Date.Year() != 2003 {
+		t.Errorf("unexpected message %+v", m)
+	}
+}
+
+func TestFromParsedOverview(t *testing.T) {
+	// Overviews of posts without References have an empty References
+	// field, which must not make the posts siblings.
+	ovs, report := nntp.ParseOverview([]string{
+		"1\tFirst topic\ta@x\t1 Jan 2020 00:00:00 GMT\t<1@x>\t\t100\t5",
+		"2\tSecond topic\tb@x\t1 Jan 2020 00:01:00 GMT\t<2@x>\t\t100\t5",
+		"3\tRe: First topic\tc@x\t1 Jan 2020 00:02:00 GMT\t<3@x>\t<1@x>\t100\t5",
+	})
+	if len(ovs) != 3 || report.Dropped() != 0 {
+		t.Fatalf("ParseOverview = %v, %+v", ovs, report)
+	}
+	var th Threader
+	for i := range ovs {
+		th.Add(FromOverview(&ovs[i]))
+	}
+	got := render(th.Threads())
+	want := "<1@x>\n  <3@x>\n<2@x>\n"
+	if got != want {
+		t.Errorf("threads:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,192 @@
+package nntp
+
+import (
+	"errors"
+	"unicode/utf8"
+)
+
+// A Wildmat is a compiled wildmat, the pattern syntax of RFC 3977
+// section 4, as used by LIST ACTIVE, NEWNEWS and newsgroup subscription
+// rules:
+//
+//   comp.*,!comp.lang.*,comp.lang.go
+//
+// A wildmat is a comma-separated list of patterns, each optionally
+// preceded by "!". The last pattern that matches decides: a plain
+// pattern accepts, a negated one rejects. If no pattern matches, the
+// string is rejected.
+//
+// In a pattern, "*" matches any sequence of characters, "?" matches one
+// character, and "[...]" matches one character from a set such as
+// "[a-z0-9]" or, with a leading "^", one not in it. A "\" makes the next
+// character literal. These last two go beyond RFC 3977 but follow INN.
+// Matching is by character, not byte, so "?" matches one UTF-8 encoded
+// character.
+type Wildmat struct {
+	pats []wildPat
+}
+
+type wildPat struct {
+	neg  bool
+	toks []wildTok
+}
+
+const (
+	wildLit = iota
+	wildAny
+	wildStar
+	wildClass
+)
+
+type wildTok struct {
+	kind   int
+	r      rune
+	neg    bool
+	ranges []rune // pairs of low, high
+}
+
+// CompileWildmat parses a wildmat.
+func CompileWildmat(s string) (*Wildmat, error) {
+	if !utf8.ValidString(s) {
+		return nil, errors.New("nntp: wildmat is not valid UTF-8")
+	}
+	w := &Wildmat{}
+	p := wildPat{}
+	if len(s) > 0 && s[0] == '!' {
+		p.neg = true
+		s = s[1:]
+	}
+	for len(s) > 0 {
+		r, n := utf8.DecodeRuneInString(s)
+		s = s[n:]
+		switch r {
+		case ',':
+			w.pats = append(w.pats, p)
+			p = wildPat{}
+			if len(s) > 0 && s[0] == '!' {
+				p.neg = true
+				s = s[1:]
+			}
+		case '*':
+			p.toks = append(p.toks, wildTok{kind: wildStar})
+		case '?':
+			p.toks = append(p.toks, wildTok{kind: wildAny})
+		case '\\':
+			if s == "" {
+				return nil, errors.New("nntp: wildmat ends in a backslash")
+			}
+			r, n = utf8.DecodeRuneInString(s)
+			s = s[n:]
+			p.toks = append(p.toks, wildTok{kind: wildLit, r: r})
+		case '[':
+			tok, rest, err := parseWildClass(s)
+			if err != nil {
+				return nil, err
+			}
+			s = rest
+			p.toks = append(p.toks, tok)
+		default:
+			p.toks = append(p.toks, wildTok{kind: wildLit, r: r})
+		}
+	}
+	w.pats = append(w.pats, p)
+	return w, nil
+}
+
+// parseWildClass parses a character class after its opening bracket and
+// returns the rest of the pattern. A "]" first in the class is literal.
+func parseWildClass(s string) (wildTok, string, error) {
+	tok := wildTok{kind: wildClass}
+	if len(s) > 0 && s[0] == '^' {
+		tok.neg = true
+		s = s[1:]
+	}
+	first := true
+	for {
+		if s == "" {
+			return tok, "", errors.New("nntp: wildmat has unterminated character class")
+		}
+		r, n := utf8.DecodeRuneInString(s)
+		s = s[n:]
+		if r == ']' && !first {
+			return tok, s, nil
+		}
+		first = false
+		if r == '\\' && s != "" {
+			r, n = utf8.DecodeRuneInString(s)
+			s = s[n:]
+		}
+		hi := r
+		if len(s) > 1 && s[0] == '-' && s[1] != ']' {
+			hi, n = utf8.DecodeRuneInString(s[1:])
+			s = s[1+n:]
+			if hi == '\\' && s != "" {
+				hi, n = utf8.DecodeRuneInString(s)
+				s = s[n:]
+			}
+		}
+		tok.ranges = append(tok.ranges, r, hi)
+	}
+}
+
+// MatchWildmat reports whether s matches the wildmat pattern. It returns
+// false if the pattern is malformed.
+func MatchWildmat(pattern, s string) bool {
+	w, err := CompileWildmat(pattern)
+	return err == nil && w.Match(s)
+}
+
+// Match reports whether s matches the wildmat.
+func (w *Wildmat) Match(s string) bool {
+	rs := []rune(s)
+	for i := len(w.pats) - 1; i >= 0; i-- {
+		if w.pats[i].match(rs) {
+			return !w.pats[i].neg
+		}
+	}
+	return false
+}
+
+func (t *wildTok) matchRune(r rune) bool {
+	switch t.kind {
+	case wildLit:
+		return t.r == r
+	case wildAny:
+		return true
+	case wildClass:
+		for i := 0; i < len(t.ranges); i += 2 {
+			if t.ranges[i] <= r && r <= t.ranges[i+1] {
+				return !t.neg
+			}
+		}
+		return t.neg
+	}
+	return false
+}
+
+// match matches the whole of s, backtracking to the last star on a
+// mismatch. Only the last star needs revisiting, so this takes at most
+// len(p.toks)*len(s) steps.
+func (p *wildPat) match(s []rune) bool {
+	pi, si := 0, 0
+	star, starS := -1, 0
+	for si < len(s) {
+		switch {
+		case pi < len(p.toks) && p.toks[pi].kind == wildStar:
+			star, starS = pi, si
+			pi++
+		case pi < len(p.toks) && p.toks[pi].matchRune(s[si]):
+			pi++
+			si++
+		case star >= 0:
+			starS++
+			pi, si = star+1, starS
+		default:
+			return false
+		}
+	}
+	for pi < len(p.toks) && p.toks[pi].kind == wildStar {
+		pi++
+	}
+	return pi == len(p.toks)
+}
@@ -0,0 +1,50 @@
+package nntp
+
+import "testing"
+
+func TestWildmat(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"comp.lang.go", "comp.lang.go", true},
+		{"comp.lang.go", "comp.lang.gol", false},
+		{"comp.*", "comp.lang.go", true},
+		{"comp.*", "alt.comp", false},
+		{"*", "", true},
+		{"", "", true},
+		{"", "a", false},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"a*b*c", "aXbYbZc", true},
+		{"a*b*c", "aXbYbZ", false},
+		{"*.binaries.*", "alt.binaries.pictures", true},
+		{"comp.*,!comp.lang.*", "comp.lang.go", false},
+		{"comp.*,!comp.lang.*", "comp.os.linux", true},
+		{"comp.*,!comp.lang.*,comp.lang.go", "comp.lang.go", true},
+		{"!comp.*", "comp.lang.go", false},
+		{"!comp.*", "alt.test", false},
+		{"*,!comp.*", "alt.test", true},
+		{"de.[a-c]*", "de.comp", true},
+		{"de.[a-c]*", "de.rec", false},
+		{"de.[^a-c]*", "de.rec", true},
+		{"[]x]", "]", true},
+		{"[a,b]", ",", true},
+		{"a\\*b", "a*b", true},
+		{"a\\*b", "axb", false},
+		{"a\\,b", "a,b", true},
+		{"fr.?tudes", "fr.études", true},
+		{"[à-ÿ]*", "été", true},
+		{"x[", "x[", false}, // malformed
+	}
+	for _, tt := range tests {
+		if got := MatchWildmat(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("MatchWildmat(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+	for _, bad := range []string{"x[", "a\\", "[^", "\xff"} {
+		if _, err := CompileWildmat(bad); err == nil {
+			t.Errorf("CompileWildmat(%q) should fail", bad)
+		}
+	}
+}